@@ -0,0 +1,210 @@
+package frontendcli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"stacklatex/latex"
+	"strings"
+)
+
+var severities = map[string]latex.Severity{
+	"info":    latex.SeverityInfo,
+	"warning": latex.SeverityWarning,
+	"error":   latex.SeverityError,
+}
+
+// Run executes a command line invocation such as "check file.tex" and returns the exit code.
+func Run(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: stacklatex <command> [arguments]\ncommands: transform, check, reverse")
+		return 2
+	}
+	switch args[0] {
+	case "transform":
+		return runTransform(args[1:])
+	case "check":
+		return runCheck(args[1:])
+	case "reverse":
+		return runReverse(args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, "unknown command "+args[0])
+		return 2
+	}
+}
+
+func runTransform(args []string) int {
+	flags := flag.NewFlagSet("transform", flag.ContinueOnError)
+	profile := flags.String("profile", latex.DefaultProfile, "target profile ("+strings.Join(latex.ProfileNames(), ", ")+")")
+	profiles := flags.String("profiles", "", "JSON file with additional target profiles")
+	asJSON := flags.Bool("json", false, "print the result of each file as JSON")
+	relations := flags.Bool("lt-gt", false, "write < and > in math as \\lt and \\gt")
+	root := flags.String("root", "", "directory \\input and \\include are resolved in, the directory of each file by default")
+	images := flags.Bool("images", false, "turn \\includegraphics and figures into html")
+	embed := flags.Bool("embed-images", false, "attach the images and print a Moodle XML questiontext element, implies -images")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *profiles != "" {
+		if err := latex.RegisterProfileFile(*profiles); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	exitCode := 0
+	for _, file := range files {
+		input, err := readInput(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		options := latex.Options{Profile: *profile, SourceFile: file, MathRelationCommands: *relations, RootDir: rootDir(*root, file),
+			Images: *images || *embed, EmbedImages: *embed}
+		result := latex.TransformLatexWithOptions(input, options)
+		if *asJSON {
+			printJSON(result)
+		} else if result.Success && *embed {
+			fmt.Print(result.MoodleQuestionText())
+		} else if result.Success {
+			fmt.Print(result.Transformed)
+		} else {
+			fmt.Fprintln(os.Stderr, file+": "+result.ErrorMessage)
+		}
+		if !result.Success {
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
+func runCheck(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	disable := flags.String("disable", "", "comma separated rule ids that are not reported")
+	severity := flags.String("severity", "", "comma separated rule=severity overrides (info, warning, error)")
+	asJSON := flags.Bool("json", false, "print the diagnostics of each file as JSON")
+	root := flags.String("root", "", "directory \\input and \\include are resolved in, the directory of each file by default")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	options := latex.LintOptions{Severity: make(map[string]latex.Severity)}
+	if *disable != "" {
+		options.Disable = strings.Split(*disable, ",")
+	}
+	if *severity != "" {
+		for _, override := range strings.Split(*severity, ",") {
+			rule, level, _ := strings.Cut(override, "=")
+			value, ok := severities[level]
+			if !ok {
+				fmt.Fprintln(os.Stderr, "unknown severity "+level+" for rule "+rule)
+				return 2
+			}
+			options.Severity[rule] = value
+		}
+	}
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	exitCode := 0
+	for _, file := range files {
+		input, err := readInput(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		options.Options.RootDir = rootDir(*root, file)
+		diagnostics := latex.Lint(input, options)
+		for _, diagnostic := range diagnostics {
+			if !*asJSON {
+				if diagnostic.Position.File == "" {
+					diagnostic.Position.File = file
+				} else {
+					diagnostic.Position.File = filepath.Join(options.Options.RootDir, diagnostic.Position.File)
+				}
+				fmt.Println(diagnostic.String())
+			}
+			if diagnostic.Severity == latex.SeverityError {
+				exitCode = 1
+			}
+		}
+		if *asJSON {
+			printJSON(checkReport{file, diagnostics})
+		}
+	}
+	return exitCode
+}
+
+// rootDir returns the directory included files of file are resolved in.
+func rootDir(root string, file string) string {
+	if root != "" {
+		return root
+	}
+	if file == "-" {
+		return "."
+	}
+	return filepath.Dir(file)
+}
+
+type checkReport struct {
+	File        string             `json:"file"`
+	Diagnostics []latex.Diagnostic `json:"diagnostics"`
+}
+
+func runReverse(args []string) int {
+	flags := flag.NewFlagSet("reverse", flag.ContinueOnError)
+	options := latex.ReverseOptions{InlineMath: latex.DelimiterDollar, DisplayMath: latex.DelimiterBrackets}
+	flags.TextVar(&options.InlineMath, "inline", options.InlineMath, "delimiters of inline math (dollar, parens, brackets or double-dollar)")
+	flags.TextVar(&options.DisplayMath, "display", options.DisplayMath, "delimiters of display math (dollar, parens, brackets or double-dollar)")
+	asJSON := flags.Bool("json", false, "print the result of each file as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	exitCode := 0
+	for _, file := range files {
+		input, err := readInput(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		result := latex.ReverseTransform(input, options)
+		if *asJSON {
+			printJSON(result)
+		} else if result.Success {
+			fmt.Print(result.Transformed)
+		} else {
+			fmt.Fprintln(os.Stderr, file+": "+result.ErrorMessage)
+		}
+		if !result.Success {
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
+func printJSON(value any) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+func readInput(file string) (string, error) {
+	if file == "-" {
+		content, err := io.ReadAll(os.Stdin)
+		return string(content), err
+	}
+	content, err := os.ReadFile(file)
+	return string(content), err
+}
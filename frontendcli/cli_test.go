@@ -0,0 +1,66 @@
+package frontendcli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunCheckExitCode(t *testing.T) {
+	dir := t.TempDir()
+	warning := filepath.Join(dir, "warning.tex")
+	failing := filepath.Join(dir, "error.tex")
+	if err := os.WriteFile(warning, []byte("$$x$$\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(failing, []byte("\\alpha\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{warning}, 0},
+		{[]string{failing}, 1},
+		{[]string{warning, failing}, 1},
+		{[]string{"-severity", "math-command-in-text=warning", failing}, 0},
+		{[]string{"-severity", "dollar-display=error", warning}, 1},
+		{[]string{"-disable", "math-command-in-text", failing}, 0},
+		{[]string{"-severity", "dollar-display=fatal", warning}, 2},
+		{[]string{"-severity", "dollar-display", warning}, 2},
+		{[]string{filepath.Join(dir, "missing.tex")}, 2},
+	}
+	for _, test := range tests {
+		if got := runCheck(test.args); got != test.want {
+			t.Errorf("runCheck(%q) = %d, want %d", test.args, got, test.want)
+		}
+	}
+}
+
+func TestRunReverseExitCode(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.html")
+	broken := filepath.Join(dir, "broken.html")
+	if err := os.WriteFile(valid, []byte("Let \\(x\\)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(broken, []byte("Let \\(x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{valid}, 0},
+		{[]string{"-inline", "parens", "-display", "double-dollar", valid}, 0},
+		{[]string{broken, valid}, 1},
+		{[]string{"-json", valid}, 0},
+		{[]string{"-json", broken, valid}, 1},
+		{[]string{"-inline", "$", valid}, 2},
+	}
+	for _, test := range tests {
+		if got := runReverse(test.args); got != test.want {
+			t.Errorf("runReverse(%q) = %d, want %d", test.args, got, test.want)
+		}
+	}
+}
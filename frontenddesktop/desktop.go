@@ -82,11 +82,20 @@ func RunDesktopApp() {
 	output.SetPlaceHolder("Output will appear here!")
 	log := widget.NewLabel("")
     info := widget.NewLabel("")
+	if err := latex.RegisterProfileFile(latex.DefaultProfileFile); err != nil {
+		log.SetText(err.Error())
+	}
+	profile := widget.NewSelect(latex.ProfileNames(), nil)
+	profile.SetSelected(latex.DefaultProfile)
 	submit := widget.NewButton("Transform to \nSTACK-compatible LaTeX", func() {
-		transformed := latex.TransformLatex(input.Text)
+		transformed := latex.TransformLatexWithOptions(input.Text, latex.Options{Profile: profile.Selected})
 		if transformed.Success {
 			output.SetText(transformed.Transformed)
-			log.SetText(transformed.Log)
+			logText := transformed.LogText()
+			for _, diagnostic := range transformed.Diagnostics {
+				logText += diagnostic.String() + "\n"
+			}
+			log.SetText(logText)
             info.SetText(transformed.Info)
 		} else {
             output.SetText("")
@@ -100,7 +109,7 @@ func RunDesktopApp() {
 	})
 
 
-	mid_content := container.New(&weightedVBox{weights: []float32{0.5, 0.1, 0.03, 0.1, 0.5}}, empty, submit, empty, copyButton, empty)
+	mid_content := container.New(&weightedVBox{weights: []float32{0.5, 0.1, 0.03, 0.1, 0.03, 0.1, 0.5}}, empty, profile, empty, submit, empty, copyButton, empty)
 	input_col := container.New(&weightedVBox{weights: []float32{0.05,0.4,0.4}}, empty, input, log)
 	output_col := container.New(&weightedVBox{weights: []float32{0.05,0.4,0.4}}, empty, output, info)
 	content := container.New(&weightedHBox{weights: []float32{0.05, 6, 0.5, 3, 0.5, 6, 0.05}}, empty, input_col, empty, mid_content, empty, output_col, empty)
@@ -1,6 +1,7 @@
 package frontendweb
 
 import (
+	"encoding/json"
 	"html/template"
 	"log"
 	"net/http"
@@ -13,22 +14,28 @@ type pageData struct {
 	Log 		  string
 	ErrorMessage  string
 	Info		  string
+	Diagnostics   []latex.Diagnostic
 	Success       bool
+	Profile       string
+	Profiles      []string
 }
 
 var tmpl = template.Must(template.ParseFiles("template.html"))
 
 func indexHandler(w http.ResponseWriter, r *http.Request) {
     log.Println("Request received:", r.Method, r.URL.Path)
-    data := pageData{}
+    data := pageData{Profile: latex.DefaultProfile, Profiles: latex.ProfileNames()}
     if r.Method == http.MethodPost {
         r.ParseForm()
         input := r.FormValue("latex_input")
-        result := latex.TransformLatex(input)
+        data.Profile = r.FormValue("profile")
+        result := latex.TransformLatexWithOptions(input, latex.Options{Profile: data.Profile})
         data.InputText = input
         data.Success = result.Success
         if result.Success {
             data.OutputText = result.Transformed
+            data.Diagnostics = result.Diagnostics
+            data.Info = result.Info
         } else {
             data.ErrorMessage = result.ErrorMessage
         }
@@ -39,8 +46,40 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
     }
 }
 
+type transformRequest struct {
+	Latex   string        `json:"latex"`
+	Options latex.Options `json:"options"`
+}
+
+// apiTransformHandler answers a POST of a transformRequest with the latex.Result as JSON.
+func apiTransformHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	var request transformRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// options that read files from the server are ignored
+	request.Options.Images = false
+	request.Options.EmbedImages = false
+	request.Options.SourceFile = ""
+	request.Options.RootDir = ""
+	result := latex.TransformLatexWithOptions(request.Latex, request.Options)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Println("JSON encoding error:", err)
+	}
+}
+
 func ServeWeb(port_num string) {
+	if err := latex.RegisterProfileFile(latex.DefaultProfileFile); err != nil {
+		log.Println("Could not load profiles:", err)
+	}
 	http.HandleFunc("/", indexHandler)
+	http.HandleFunc("/api/transform", apiTransformHandler)
 	log.Println("Listening on port " + port_num)
 	go http.ListenAndServe("0.0.0.0:" + port_num, nil) // IPv4
     go http.ListenAndServe("[::]:" + port_num, nil)    // IPv6
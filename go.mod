@@ -1,6 +1,6 @@
 module stacklatex
 
-go 1.22.3
+go 1.24
 
 require fyne.io/fyne/v2 v2.6.1
 
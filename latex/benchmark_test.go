@@ -0,0 +1,42 @@
+package latex
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const benchmarkParagraph = `Let $f(x) = \abs{x}^2$ and \(g\) be given. % a comment
+\begin{enumerate}
+\item Show that $$\int_0^1 f(x)\,dx = \frac{1}{3}$$ holds.
+\item Na\"ive 'estimates' -- see \ref{eq:bench}.
+\end{enumerate}
+\begin{align}
+a &= b + c \\
+d &= e
+\end{align}
+`
+
+func benchmarkInput(size int) string {
+	var input strings.Builder
+	for input.Len() < size {
+		input.WriteString(benchmarkParagraph)
+	}
+	return input.String()
+}
+
+// The time per byte should stay the same for growing inputs.
+func BenchmarkTransform(b *testing.B) {
+	for _, size := range []int{64 << 10, 1 << 20, 4 << 20} {
+		input := benchmarkInput(size)
+		b.Run(strconv.Itoa(size>>10)+"KiB", func(b *testing.B) {
+			b.SetBytes(int64(len(input)))
+			for i := 0; i < b.N; i++ {
+				if _, err := Transform(strings.NewReader(input), io.Discard, Options{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -24,16 +24,17 @@ func customCommandDependencies() map[string][]string {
 	}
 }
 
-type commandReplacement struct {
-	escapeRepl		bool
-	argCommand		bool
-	optArgCommand 	bool
-	leftRepl		string
-	rightRepl		string
+// CommandReplacement replaces a command, and its argument if it takes one, for RuleSet.CommandReplacements.
+type CommandReplacement struct {
+	EscapeRepl		bool // the replacement is written like input, so it is escaped for Markdown
+	ArgCommand		bool // the command takes an argument, which is transformed between LeftRepl and RightRepl
+	OptArgCommand 	bool // the command takes an optional argument in brackets
+	LeftRepl		string
+	RightRepl		string
 }
 
-func GetCommandReplacements() map[string]commandReplacement {
-	return map[string]commandReplacement{
+func GetCommandReplacements() map[string]CommandReplacement {
+	return map[string]CommandReplacement{
 		"R": {true, false, false, "\\mathbb{R}", ""},
 		"mbox": {true, true, false, "{", "}"},
 		"Tilde": {true, true, false, "\\tilde{", "}"},
@@ -42,7 +43,7 @@ func GetCommandReplacements() map[string]commandReplacement {
 }
 
 func CreateCustomCommandPreamble(usedCustomCommands *map[string]bool, info *latexTransformationInfo) string {
-	dependencyMap := customCommandDependencies()
+	dependencyMap := info.commands.customCommandDependencies
 	for comm := range *usedCustomCommands {
 		dependencies, ok := dependencyMap[comm]
 		if ok {
@@ -51,8 +52,8 @@ func CreateCustomCommandPreamble(usedCustomCommands *map[string]bool, info *late
 			}
 		}
 	}
-	ordered_comms := customCommandsInOrder()
-	command_map := GetCustomCommands()
+	ordered_comms := info.commands.customCommandOrder
+	command_map := info.commands.customCommands
 	prelude_defs := make([]string, 0)
 	for _, comm := range ordered_comms {
 		_, ok := (*usedCustomCommands)[comm]
@@ -65,11 +66,12 @@ func CreateCustomCommandPreamble(usedCustomCommands *map[string]bool, info *late
 	if len(prelude_defs) == 0 {
 		return ""
 	} else {
-		prelude_string := "\\("
+		open, close := info.inlineDelimiters()
+		prelude_string := open
 		for _, def := range prelude_defs {
 			prelude_string += def + " "
 		}
-		prelude_string += "\\)"
+		prelude_string += close
 		return prelude_string
 	}
 }
\ No newline at end of file
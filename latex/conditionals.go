@@ -0,0 +1,182 @@
+package latex
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type conditional struct {
+	value        bool
+	parentTaking bool
+	inElse       bool
+	texDepth     int // TeX conditionals opened in the active branch, their \else and \fi are kept
+	command      string
+	start        Position
+}
+
+func (c conditional) taking() bool {
+	return c.parentTaking && c.value != c.inElse
+}
+
+type skipState struct {
+	backslash bool
+	word      string
+	comment   bool
+}
+
+// TeX primitives whose \fi has to be matched while skipping an inactive branch
+var texConditionals = map[string]bool{
+	"if": true, "ifx": true, "ifnum": true, "ifdim": true, "ifodd": true, "ifcase": true,
+	"ifcat": true, "ifvmode": true, "ifhmode": true, "ifmmode": true, "ifinner": true,
+	"ifvoid": true, "ifhbox": true, "ifvbox": true, "ifeof": true, "ifdefined": true, "ifcsname": true,
+}
+
+func GetDefaultFlags() map[string]bool {
+	return map[string]bool{
+		"stack": true,
+	}
+}
+
+// handleConditionalCommand handles \iftrue, \iffalse, \ifstack and \if<flag> together with their
+// \else and \fi, as well as \newif declarations and \<flag>true / \<flag>false switches.
+func handleConditionalCommand(command string, char rune, info *latexTransformationInfo) (bool, error) {
+	switch {
+	case command == "newif":
+		info.setPrevToken(token{newifDecl, ""})
+		return true, handlePrevNewifDecl(char, info)
+	case (command == "else" || command == "fi") && len(info.conditionals) > 0 && info.conditionals[len(info.conditionals)-1].texDepth > 0:
+		if command == "fi" {
+			info.conditionals[len(info.conditionals)-1].texDepth -= 1
+		}
+		return false, nil
+	case command == "else" && len(info.conditionals) > 0:
+		top := &info.conditionals[len(info.conditionals)-1]
+		top.inElse = true
+	case command == "fi" && len(info.conditionals) > 0:
+		info.conditionals = info.conditionals[0 : len(info.conditionals)-1]
+	case strings.HasPrefix(command, "if"):
+		value, ok := info.conditionalValue(command[2:])
+		if !ok {
+			if texConditionals[command] && len(info.conditionals) > 0 {
+				info.conditionals[len(info.conditionals)-1].texDepth += 1
+			}
+			return false, nil
+		}
+		info.log("Resolved conditional \\" + command)
+		info.conditionals = append(info.conditionals, conditional{value, !info.isSkipping(), false, 0, command, info.tokenPos})
+	default:
+		flag, value, ok := cutFlagSwitch(command)
+		if !ok || !info.isDeclaredFlag(flag) {
+			return false, nil
+		}
+		info.log("Applied \\" + command)
+		info.flags[flag] = value
+	}
+	info.setPrevToken(token{none, ""})
+	if char == ' ' || char == '\n' {
+		return true, nil
+	}
+	return true, handleCharacter(char, info)
+}
+
+func cutFlagSwitch(command string) (string, bool, bool) {
+	if flag, found := strings.CutSuffix(command, "true"); found && flag != "" {
+		return flag, true, true
+	}
+	if flag, found := strings.CutSuffix(command, "false"); found && flag != "" {
+		return flag, false, true
+	}
+	return "", false, false
+}
+
+func handlePrevNewifDecl(char rune, info *latexTransformationInfo) error {
+	name := info.getTokenInfo()
+	if name == "" && char == '\\' {
+		info.addTokenInfo(string(char))
+		return nil
+	}
+	if name != "" && unicode.IsLetter(char) {
+		info.addTokenInfo(string(char))
+		return nil
+	}
+	info.setPrevToken(token{none, ""})
+	flag, found := strings.CutPrefix(name, "\\if")
+	if !found || flag == "" {
+		// not a conditional declaration after all, keep it as written
+		info.addToOutputString("\\newif")
+		for _, replayed := range name + string(char) {
+			if err := handleCharacter(replayed, info); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !info.isDeclaredFlag(flag) {
+		info.flags[flag] = false
+	}
+	info.log("Removed \\newif" + name)
+	if char == ' ' || char == '\n' {
+		return nil
+	}
+	return handleCharacter(char, info)
+}
+
+// handleSkippedChar consumes the characters of an inactive conditional branch. Only control
+// words are looked at, to find the matching \else and \fi.
+func handleSkippedChar(char rune, info *latexTransformationInfo) error {
+	state := &info.skip
+	if state.comment {
+		state.comment = char != '\n'
+		return nil
+	}
+	if state.backslash {
+		if unicode.IsLetter(char) {
+			state.word += string(char)
+			return nil
+		}
+		word := state.word
+		state.backslash = false
+		state.word = ""
+		if word != "" {
+			return handleSkippedCommand(word, char, info)
+		}
+		return nil
+	}
+	switch char {
+	case '\\':
+		state.backslash = true
+	case '%':
+		state.comment = true
+	}
+	return nil
+}
+
+func handleSkippedCommand(command string, char rune, info *latexTransformationInfo) error {
+	top := &info.conditionals[len(info.conditionals)-1]
+	switch {
+	case command == "else":
+		top.inElse = true
+	case command == "fi":
+		info.conditionals = info.conditionals[0 : len(info.conditionals)-1]
+	case strings.HasPrefix(command, "if"):
+		value, ok := info.conditionalValue(command[2:])
+		if !ok && !texConditionals[command] {
+			return handleSkippedChar(char, info)
+		}
+		// skipped commands are not tokens, the command ends right before char
+		start := info.pos
+		start.Column -= utf8.RuneCountInString(command) + 1
+		info.conditionals = append(info.conditionals, conditional{value, false, false, 0, command, start})
+	default:
+		return handleSkippedChar(char, info)
+	}
+	if info.isSkipping() {
+		return handleSkippedChar(char, info)
+	}
+	info.log("Removed inactive conditional branch")
+	if char == ' ' || char == '\n' {
+		return nil
+	}
+	return handleCharacter(char, info)
+}
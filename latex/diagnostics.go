@@ -0,0 +1,78 @@
+package latex
+
+import (
+	"errors"
+	"strconv"
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return "severity(" + strconv.Itoa(int(s)) + ")"
+	}
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	for severity := SeverityInfo; severity <= SeverityError; severity++ {
+		if severity.String() == string(text) {
+			*s = severity
+			return nil
+		}
+	}
+	return errors.New("unknown severity " + string(text))
+}
+
+// Position is a 1-based line and column (in characters) of the input. File names the included
+// file the position is in, it is empty for the input itself.
+type Position struct {
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	File   string `json:"file,omitempty"`
+}
+
+// String formats the position as file:line:column. An unknown position, with Line 0, only
+// names the file.
+func (p Position) String() string {
+	if p.Line == 0 {
+		return p.File
+	}
+	position := strconv.Itoa(p.Line) + ":" + strconv.Itoa(p.Column)
+	if p.File != "" {
+		return p.File + ":" + position
+	}
+	return position
+}
+
+// Diagnostic is a problem found in the input that did not stop the transformation.
+type Diagnostic struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Position Position `json:"position"`
+	Message  string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	message := d.Severity.String() + ": " + d.Message + " [" + d.Rule + "]"
+	if position := d.Position.String(); position != "" {
+		return position + ": " + message
+	}
+	return message
+}
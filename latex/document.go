@@ -0,0 +1,296 @@
+package latex
+
+import (
+	"errors"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const (
+	documentClassMarker = "\\documentclass"
+	documentBeginMarker = "\\begin{document}"
+)
+
+// prefixReader returns the runes of prefix before those of reader.
+type prefixReader struct {
+	prefix []rune
+	reader io.RuneReader
+}
+
+func (p *prefixReader) ReadRune() (rune, int, error) {
+	if len(p.prefix) > 0 {
+		char := p.prefix[0]
+		p.prefix = p.prefix[1:]
+		return char, len(string(char)), nil
+	}
+	return p.reader.ReadRune()
+}
+
+// readPreamble reads the preamble of a full document up to \begin{document} and harvests its
+// definitions. Input that is not a full document is returned unchanged.
+func readPreamble(reader io.RuneReader, info *latexTransformationInfo) (io.RuneReader, error) {
+	if info.options.Document == DocumentBody {
+		return reader, nil
+	}
+	read := make([]rune, 0)
+	if info.options.Document == DocumentAuto {
+		// a full document starts with \documentclass after spaces and comments
+		content := ""
+		inComment := false
+		for content != documentClassMarker {
+			char, _, err := reader.ReadRune()
+			if err == io.EOF {
+				return &prefixReader{read, reader}, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			read = append(read, char)
+			switch {
+			case inComment:
+				inComment = char != '\n'
+			case content == "" && unicode.IsSpace(char):
+			case content == "" && char == '%':
+				inComment = true
+			default:
+				content += string(char)
+				if !strings.HasPrefix(documentClassMarker, content) {
+					return &prefixReader{read, reader}, nil
+				}
+			}
+		}
+	}
+	for _, char := range read {
+		info.advancePosition(char)
+	}
+	var preamble strings.Builder
+	preamble.WriteString(string(read))
+	// the marker starts with its only backslash, so a mismatch restarts the match
+	matched, inComment, escaped := 0, false, false
+	for matched < len(documentBeginMarker) {
+		char, _, err := reader.ReadRune()
+		if err == io.EOF {
+			return nil, errors.New(documentBeginMarker + " is missing")
+		}
+		if err != nil {
+			return nil, err
+		}
+		info.advancePosition(char)
+		preamble.WriteRune(char)
+		switch {
+		case inComment:
+			inComment = char != '\n'
+			continue
+		case char == '%' && !escaped:
+			inComment = true
+			matched = 0
+		case char == rune(documentBeginMarker[matched]) && (matched > 0 || !escaped):
+			matched += 1
+		case char == '\\' && !escaped:
+			matched = 1
+		default:
+			matched = 0
+		}
+		escaped = char == '\\' && !escaped
+	}
+	info.log("Removed preamble")
+	info.harvestPreamble(strings.TrimSuffix(preamble.String(), documentBeginMarker))
+	info.addEnvironment("document")
+	info.document = true
+	// the line break after \begin{document} is not part of the body
+	char, _, err := reader.ReadRune()
+	if err == io.EOF {
+		return reader, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if char == '\n' {
+		info.advancePosition(char)
+		return reader, nil
+	}
+	return &prefixReader{[]rune{char}, reader}, nil
+}
+
+// harvestPreamble takes the macros, operators, theorem environments and babel language of a
+// preamble over into the transformation.
+func (l *latexTransformationInfo) harvestPreamble(preamble string) {
+	preamble = stripComments(preamble)
+	if l.germanShorthands == nil && usesGermanBabel(preamble) {
+		l.germanShorthands = GetGermanShorthands()
+	}
+	if language := babelLanguage(preamble); language != "" && l.options.Language == "" {
+		l.log("Harvested babel language " + language)
+		l.theoremNames = GetTheoremNames(language)
+		maps.Copy(l.theoremNames, l.options.TheoremNames)
+	} else {
+		l.theoremNames = maps.Clone(l.theoremNames)
+	}
+	commands := maps.Clone(l.commands.customCommands)
+	order := slices.Clone(l.commands.customCommandOrder)
+	harvested := make([]string, 0)
+	addCommand := func(macro string, definition string) {
+		if _, exists := commands[macro]; !exists {
+			order = append(order, macro)
+		}
+		commands[macro] = definition
+		harvested = append(harvested, macro)
+		l.log("Harvested definition of \\" + macro)
+	}
+	l.environmentReplacements = maps.Clone(l.environmentReplacements)
+	for rest := preamble; ; {
+		start := strings.IndexByte(rest, '\\')
+		if start < 0 {
+			break
+		}
+		rest = rest[start+1:]
+		command := leadingLetters(rest)
+		rest = rest[len(command):]
+		star := strings.HasPrefix(rest, "*")
+		if star {
+			rest = rest[1:]
+		}
+		switch command {
+		case "newcommand", "renewcommand", "providecommand":
+			macro, after, ok := cutMacroName(rest)
+			if !ok {
+				continue
+			}
+			arguments := ""
+			for i := 0; i < 2; i++ {
+				if argument, next, ok := cutDelimited(strings.TrimLeft(after, " \n"), '[', ']'); ok {
+					arguments += "[" + argument + "]"
+					after = next
+				}
+			}
+			body, next := cutGroup(after)
+			if next == after {
+				continue
+			}
+			rest = next
+			addCommand(macro, "\\newcommand{\\"+macro+"}"+arguments+"{"+body+"}")
+		case "DeclareMathOperator":
+			macro, after, ok := cutMacroName(rest)
+			if !ok {
+				continue
+			}
+			name, next := cutGroup(after)
+			if next == after {
+				continue
+			}
+			rest = next
+			operator := "\\operatorname"
+			if star {
+				operator += "*"
+			}
+			addCommand(macro, "\\newcommand{\\"+macro+"}{"+operator+"{"+name+"}}")
+		case "newtheorem":
+			environ, after := cutGroup(rest)
+			if after == rest {
+				continue
+			}
+			if _, next, ok := cutDelimited(strings.TrimLeft(after, " \n"), '[', ']'); ok {
+				after = next
+			}
+			title, next := cutGroup(after)
+			if next == after {
+				continue
+			}
+			rest = next
+			environ = strings.TrimSpace(environ)
+			l.environmentReplacements[environ] = theoremEnvReplacement(environ, !star)
+			l.theoremNames[environ] = strings.TrimSpace(title)
+			l.log("Harvested theorem environment " + environ)
+		}
+	}
+	dependencies := maps.Clone(l.commands.customCommandDependencies)
+	for _, macro := range harvested {
+		dependencies[macro] = commandDependencies(macro, commands, map[string]bool{macro: true})
+	}
+	l.commands.customCommands = commands
+	l.commands.customCommandOrder = order
+	l.commands.customCommandDependencies = dependencies
+}
+
+// commandDependencies lists the custom commands the definition of macro uses, directly or not.
+func commandDependencies(macro string, commands map[string]string, seen map[string]bool) []string {
+	dependencies := make([]string, 0)
+	definition := commands[macro]
+	for rest := definition; ; {
+		start := strings.IndexByte(rest, '\\')
+		if start < 0 {
+			break
+		}
+		rest = rest[start+1:]
+		name := leadingLetters(rest)
+		rest = rest[len(name):]
+		if _, custom := commands[name]; !custom || seen[name] {
+			continue
+		}
+		seen[name] = true
+		dependencies = append(dependencies, name)
+		dependencies = append(dependencies, commandDependencies(name, commands, seen)...)
+	}
+	return dependencies
+}
+
+// cutMacroName splits the macro name of a definition, given as {\name} or \name, off s.
+func cutMacroName(s string) (string, string, bool) {
+	s = strings.TrimLeft(s, " \n")
+	if strings.HasPrefix(s, "{") {
+		group, rest := cutGroup(s)
+		group = strings.TrimSpace(group)
+		if !strings.HasPrefix(group, "\\") || leadingLetters(group[1:]) != group[1:] {
+			return "", s, false
+		}
+		return group[1:], rest, group != "\\"
+	}
+	if !strings.HasPrefix(s, "\\") {
+		return "", s, false
+	}
+	name := leadingLetters(s[1:])
+	return name, s[1+len(name):], name != ""
+}
+
+func leadingLetters(s string) string {
+	end := strings.IndexFunc(s, func(char rune) bool { return !unicode.IsLetter(char) })
+	if end < 0 {
+		return s
+	}
+	return s[:end]
+}
+
+// stripComments removes % comments, keeping escaped \%.
+func stripComments(s string) string {
+	var stripped strings.Builder
+	for _, line := range strings.SplitAfter(s, "\n") {
+		for i := 0; i < len(line); i++ {
+			if line[i] == '\\' {
+				i++
+			} else if line[i] == '%' {
+				line = line[:i] + line[len(strings.TrimSuffix(line, "\n")):]
+				break
+			}
+		}
+		stripped.WriteString(line)
+	}
+	return stripped.String()
+}
+
+// babelLanguage returns the theorem language of the main babel language, the last option.
+func babelLanguage(preamble string) string {
+	language := ""
+	for _, match := range babelOptionsPattern.FindAllStringSubmatch(preamble, -1) {
+		options := strings.Split(match[1], ",")
+		switch strings.TrimSpace(options[len(options)-1]) {
+		case "ngerman", "german", "naustrian", "austrian", "nswissgerman", "swissgerman":
+			language = "de"
+		case "english", "british", "american", "UKenglish", "USenglish":
+			language = "en"
+		}
+	}
+	return language
+}
@@ -1,53 +1,129 @@
 package latex
 
-type envReplacement struct {
-	escapeRepl 	bool
-	leftRepl	string
-	rightRepl	string
-	innerRepl 	map[string]commandReplacement
+// EnvReplacement replaces the delimiters and inner commands of an environment for RuleSet.EnvReplacements.
+type EnvReplacement struct {
+	EscapeRepl 	bool
+	LeftRepl	string
+	RightRepl	string
+	InnerRepl 	map[string]CommandReplacement // replacements of commands inside the environment
+	OptArg		bool // the environment takes an optional argument in brackets
+	Heading		string // theorem name key, the environment starts with a bold heading
+	Numbered	bool // the heading is numbered if Options.NumberTheorems is set
 }
 
-func GetEnvReplacements() map[string]envReplacement {
-	return map[string]envReplacement {
+func GetEnvReplacements() map[string]EnvReplacement {
+	return map[string]EnvReplacement {
 		"enumerate": {
-			escapeRepl: false,
-			leftRepl: "<ol>",
-			rightRepl: "</ol>",
-			innerRepl: map[string]commandReplacement{
+			EscapeRepl: false,
+			LeftRepl: "<ol>",
+			RightRepl: "</ol>",
+			InnerRepl: map[string]CommandReplacement{
 				"item": {
-					argCommand: false,
-					optArgCommand: false,
-					leftRepl: "<li>",
-					rightRepl: "",
+					ArgCommand: false,
+					OptArgCommand: false,
+					LeftRepl: "<li>",
+					RightRepl: "",
 				},
 			},
 		},
 		"itemize": {
-			escapeRepl: false,
-			leftRepl: "<ul>",
-			rightRepl: "</ul>",
-			innerRepl: map[string]commandReplacement{
+			EscapeRepl: false,
+			LeftRepl: "<ul>",
+			RightRepl: "</ul>",
+			InnerRepl: map[string]CommandReplacement{
 				"item": {
-					argCommand: false,
-					optArgCommand: false,
-					leftRepl: "<li>",
-					rightRepl: "",
+					ArgCommand: false,
+					OptArgCommand: false,
+					LeftRepl: "<li>",
+					RightRepl: "",
 				},
 			},
 		}, 
 		"description": {
-			escapeRepl: false,
-			leftRepl: "",
-			rightRepl: "",
-			innerRepl: map[string]commandReplacement{
+			EscapeRepl: false,
+			LeftRepl: "",
+			RightRepl: "",
+			InnerRepl: map[string]CommandReplacement{
 				"item": {
-					argCommand: false,
-					optArgCommand: true,
-					leftRepl: "",
-					rightRepl: "",
+					ArgCommand: false,
+					OptArgCommand: true,
+					LeftRepl: "",
+					RightRepl: "",
 				},
 			},
 		},
+		"theorem": theoremEnvReplacement("theorem", true),
+		"lemma": theoremEnvReplacement("lemma", true),
+		"definition": theoremEnvReplacement("definition", true),
+		"remark": theoremEnvReplacement("remark", true),
+		"example": theoremEnvReplacement("example", true),
+		"proof": {
+			EscapeRepl: false,
+			LeftRepl: "<div class=\"proof\">",
+			RightRepl: " &#9633;</div>",
+			OptArg: true,
+			Heading: "proof",
+			Numbered: false,
+		},
+	}
+}
+
+func theoremEnvReplacement(heading string, numbered bool) EnvReplacement {
+	return EnvReplacement{
+		EscapeRepl: false,
+		LeftRepl: "<div class=\"" + heading + "\">",
+		RightRepl: "</div>",
+		OptArg: true,
+		Heading: heading,
+		Numbered: numbered,
+	}
+}
+
+func GetTheoremNames(language string) map[string]string {
+	switch language {
+	case "en":
+		return map[string]string{
+			"theorem": "Theorem",
+			"lemma": "Lemma",
+			"definition": "Definition",
+			"remark": "Remark",
+			"example": "Example",
+			"proof": "Proof",
+		}
+	default:
+		return map[string]string{
+			"theorem": "Satz",
+			"lemma": "Lemma",
+			"definition": "Definition",
+			"remark": "Bemerkung",
+			"example": "Beispiel",
+			"proof": "Beweis",
+		}
+	}
+}
+
+// VerbatimArg tells which arguments a verbatim environment takes before its body.
+type VerbatimArg int
+const (
+	NoVerbatimArg VerbatimArg = iota
+	// OptionsVerbatimArg reads the language from [language=...] like lstlisting.
+	OptionsVerbatimArg
+	// LanguageVerbatimArg reads the language from {...} like minted.
+	LanguageVerbatimArg
+)
+
+func GetVerbatimEnvirons() map[string]VerbatimArg {
+	return map[string]VerbatimArg {
+		"verbatim": NoVerbatimArg,
+		"verbatim*": NoVerbatimArg,
+		"lstlisting": OptionsVerbatimArg,
+		"minted": LanguageVerbatimArg,
+	}
+}
+
+func GetCommentEnvirons() map[string]bool {
+	return map[string]bool {
+		"comment": true,
 	}
 }
 
@@ -56,7 +132,15 @@ func GetKnownMathEnvirons() map[string]bool {
 		"align": true,
 		"align*": true,
 		"aligned": true,
+		"alignat": true,
+		"alignat*": true,
 		"equation": true,
 		"equation*": true,
+		"flalign": true,
+		"flalign*": true,
+		"gather": true,
+		"gather*": true,
+		"multline": true,
+		"multline*": true,
 	}
 }
\ No newline at end of file
@@ -0,0 +1,42 @@
+package latex
+
+import (
+	"errors"
+	"strconv"
+)
+
+func handleFootnote(char rune, info *latexTransformationInfo) error {
+	if char != '{' {
+		return errors.New("expected { for command footnote")
+	}
+	index := len(info.footnotes)
+	number := strconv.Itoa(index + 1)
+	info.log("Moved \\footnote{...} to the list of footnotes")
+	info.addRawToOutputString("<sup id=\"fnref-" + number + "\"><a href=\"#fn-" + number + "\">" + number + "</a></sup>")
+	// the footnote text is transformed in place and cut out of the output at its closing brace
+	start := info.output.Len()
+	mathOpen := info.isMathModeActive()
+	info.footnotes = append(info.footnotes, "")
+	info.pushClosingBraceAction(braceClosingData{false, info.getOpenBraces(), "", func(info *latexTransformationInfo) {
+		if info.isMathModeActive() != mathOpen {
+			info.err = errors.New("math mode is not closed in footnote")
+		}
+		info.footnotes[index] = string(info.output.Bytes()[start:])
+		info.output.Truncate(start)
+	}})
+	braceCheck(info, char)
+	info.setPrevToken(token{none, ""})
+	return nil
+}
+
+func (l *latexTransformationInfo) footnoteList() string {
+	if len(l.footnotes) == 0 {
+		return ""
+	}
+	list := "<hr><ol class=\"footnotes\">"
+	for i, footnote := range l.footnotes {
+		number := strconv.Itoa(i + 1)
+		list += "<li id=\"fn-" + number + "\">" + footnote + " <a href=\"#fnref-" + number + "\">&#8617;</a></li>"
+	}
+	return list + "</ol>"
+}
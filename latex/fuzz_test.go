@@ -0,0 +1,83 @@
+package latex
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func addGoldenSeeds(f *testing.F) {
+	inputs, _ := filepath.Glob(filepath.Join("testdata", "golden", "*.tex"))
+	for _, input := range inputs {
+		source, err := os.ReadFile(input)
+		if err == nil {
+			f.Add(string(source))
+		}
+	}
+}
+
+// unbalancedMath returns a description of the first misplaced math delimiter in output, ignoring code.
+func unbalancedMath(output string) string {
+	open := ""
+	for i := 0; i < len(output); i++ {
+		if strings.HasPrefix(output[i:], "<code") {
+			end := strings.Index(output[i:], "</code>")
+			if end < 0 {
+				return "unclosed <code>"
+			}
+			i += end
+			continue
+		}
+		if output[i] != '\\' || i+1 == len(output) {
+			continue
+		}
+		i++
+		switch delimiter := output[i-1 : i+1]; delimiter {
+		case "\\(", "\\[":
+			if open != "" {
+				return delimiter + " inside " + open
+			}
+			open = delimiter
+		case "\\)", "\\]":
+			if mathDelimiterPairs[open] != delimiter {
+				return delimiter + " without matching opening"
+			}
+			open = ""
+		}
+	}
+	if open != "" {
+		return open + " is not closed"
+	}
+	return ""
+}
+
+func FuzzTransformLatex(f *testing.F) {
+	addGoldenSeeds(f)
+	f.Add("\\begin{itemize}\\item $x$\\end{itemize}")
+	f.Add("%")
+	f.Add("\\")
+	f.Fuzz(func(t *testing.T, input string) {
+		result := TransformLatex(input)
+		if strings.Contains(result.ErrorMessage, "internal error") {
+			t.Fatalf("transforming %q: %s", input, result.ErrorMessage)
+		}
+		if !result.Success || slices.ContainsFunc(result.Diagnostics, func(d Diagnostic) bool { return d.Rule == unclosedRule }) {
+			return
+		}
+		if problem := unbalancedMath(result.Transformed); problem != "" {
+			t.Errorf("transforming %q gave %q: %s", input, result.Transformed, problem)
+		}
+	})
+}
+
+func FuzzReverseTransform(f *testing.F) {
+	addGoldenSeeds(f)
+	f.Add("<ol><li type=\"a\">) \\(x\\)</li></ol>")
+	f.Add("<div class=\"theorem\"><strong>")
+	f.Fuzz(func(t *testing.T, input string) {
+		ReverseTransform(input, ReverseOptions{})
+		ReverseTransform(TransformLatex(input).Transformed, ReverseOptions{})
+	})
+}
@@ -0,0 +1,83 @@
+package latex
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Regenerate the expectations after an intentional change with
+//
+//	go test ./latex -run TestGolden -update
+var update = flag.Bool("update", false, "rewrite the expected .out and .log files in testdata/golden")
+
+// goldenResult renders a result as the expected output and log files.
+func goldenResult(input string, options Options) (string, string) {
+	result := TransformLatexWithOptions(input, options)
+	out := result.Transformed
+	if len(result.Files) > 0 {
+		// embedded files are only visible in the Moodle XML export
+		out = result.MoodleQuestionText()
+	}
+	if !result.Success {
+		out = "error: " + result.ErrorMessage + "\n"
+	}
+	log := result.LogText()
+	for _, diagnostic := range result.Diagnostics {
+		log += diagnostic.String() + "\n"
+	}
+	return out, log
+}
+
+func TestGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "golden", "*.tex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("no golden files found")
+	}
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".tex")
+		t.Run(name, func(t *testing.T) {
+			source, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			base := strings.TrimSuffix(input, ".tex")
+			var options Options
+			// options are read from an optional <name>.json next to the input
+			if encoded, err := os.ReadFile(base + ".json"); err == nil {
+				if err := json.Unmarshal(encoded, &options); err != nil {
+					t.Fatal(err)
+				}
+			}
+			out, log := goldenResult(string(source), options)
+			if *update {
+				if err := os.WriteFile(base+".out", []byte(out), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(base+".log", []byte(log), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			compareGolden(t, base+".out", out)
+			compareGolden(t, base+".log", log)
+		})
+	}
+}
+
+func compareGolden(t *testing.T, path string, got string) {
+	t.Helper()
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs\n--- got ---\n%s\n--- want ---\n%s", path, got, want)
+	}
+}
@@ -0,0 +1,156 @@
+package latex
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+type htmlTokenType int
+
+const (
+	htmlText htmlTokenType = iota
+	htmlStartTag
+	htmlEndTag
+)
+
+type htmlAttribute struct {
+	name  string
+	value string
+}
+
+type htmlToken struct {
+	ttype       htmlTokenType
+	name        string // lower case tag name
+	attributes  []htmlAttribute
+	selfClosing bool
+	text        string // raw text, or the raw tag for start and end tags
+	offset      int
+}
+
+func (t htmlToken) attribute(name string) (string, bool) {
+	for _, attr := range t.attributes {
+		if attr.name == name {
+			return attr.value, true
+		}
+	}
+	return "", false
+}
+
+// formatAttribute writes an attribute with its value escaped for a quoted attribute.
+func formatAttribute(name string, value string) string {
+	return " " + name + "=\"" + html.EscapeString(value) + "\""
+}
+
+func isVoidElement(name string) bool {
+	switch name {
+	case "br", "hr", "img", "input", "meta", "link", "wbr", "col", "area", "source":
+		return true
+	}
+	return false
+}
+
+// tokenizeHTML splits s into text and tags. A < that does not start a tag and comments are kept
+// as text; entities are not decoded.
+func tokenizeHTML(s string) []htmlToken {
+	tokens := make([]htmlToken, 0)
+	textStart := 0
+	for i := 0; i < len(s); {
+		if s[i] != '<' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(s[i:], "<!--") {
+			// comments stay in the text
+			end := strings.Index(s[i+4:], "-->")
+			if end < 0 {
+				break
+			}
+			i += end + 7
+			continue
+		}
+		tag, length, ok := parseTag(s[i:])
+		if !ok {
+			i++
+			continue
+		}
+		if textStart < i {
+			tokens = append(tokens, htmlToken{ttype: htmlText, text: s[textStart:i], offset: textStart})
+		}
+		tag.offset = i
+		tokens = append(tokens, tag)
+		i += length
+		textStart = i
+	}
+	if textStart < len(s) {
+		tokens = append(tokens, htmlToken{ttype: htmlText, text: s[textStart:], offset: textStart})
+	}
+	return tokens
+}
+
+func parseTag(s string) (htmlToken, int, bool) {
+	tag := htmlToken{ttype: htmlStartTag}
+	i := 1
+	if i < len(s) && s[i] == '/' {
+		tag.ttype = htmlEndTag
+		i++
+	}
+	nameStart := i
+	for i < len(s) && (isASCIILetter(s[i]) || (i > nameStart && s[i] >= '0' && s[i] <= '9')) {
+		i++
+	}
+	if i == nameStart {
+		return tag, 0, false
+	}
+	tag.name = strings.ToLower(s[nameStart:i])
+	for {
+		for i < len(s) && unicode.IsSpace(rune(s[i])) {
+			i++
+		}
+		if i >= len(s) {
+			return tag, 0, false
+		}
+		switch {
+		case s[i] == '>':
+			tag.text = s[0 : i+1]
+			return tag, i + 1, true
+		case strings.HasPrefix(s[i:], "/>"):
+			tag.selfClosing = true
+			tag.text = s[0 : i+2]
+			return tag, i + 2, true
+		}
+		nameStart = i
+		for i < len(s) && (isASCIILetter(s[i]) || (s[i] >= '0' && s[i] <= '9') || strings.IndexByte("-_:", s[i]) >= 0) {
+			i++
+		}
+		if i == nameStart {
+			return tag, 0, false
+		}
+		attr := htmlAttribute{name: strings.ToLower(s[nameStart:i])}
+		if i < len(s) && s[i] == '=' {
+			i++
+			if i >= len(s) {
+				return tag, 0, false
+			}
+			if quote := s[i]; quote == '"' || quote == '\'' {
+				end := strings.IndexByte(s[i+1:], quote)
+				if end < 0 {
+					return tag, 0, false
+				}
+				attr.value = s[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				valueStart := i
+				for i < len(s) && !unicode.IsSpace(rune(s[i])) && s[i] != '>' {
+					i++
+				}
+				attr.value = s[valueStart:i]
+			}
+		}
+		tag.attributes = append(tag.attributes, attr)
+	}
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
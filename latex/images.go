@@ -0,0 +1,245 @@
+package latex
+
+import (
+	"encoding/base64"
+	"errors"
+	"html"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// EmbeddedFile is an image referenced as @@PLUGINFILE@@ in the transformed output.
+type EmbeddedFile struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Content string `json:"content"` // base64 encoded file content
+}
+
+// MoodleXML returns the <file> element that attaches the file to a Moodle XML question text.
+func (f EmbeddedFile) MoodleXML() string {
+	return "<file name=\"" + html.EscapeString(f.Name) + "\" path=\"" + html.EscapeString(f.Path) + "\" encoding=\"base64\">" + f.Content + "</file>"
+}
+
+func GetFigureEnvReplacements() map[string]EnvReplacement {
+	figure := EnvReplacement{
+		EscapeRepl: false,
+		LeftRepl:   "<figure>",
+		RightRepl:  "</figure>",
+		InnerRepl: map[string]CommandReplacement{
+			"caption":   {false, true, false, "<figcaption>", "</figcaption>"},
+			"centering": {false, false, false, "", ""},
+		},
+		OptArg: true,
+	}
+	return map[string]EnvReplacement{
+		"figure":  figure,
+		"figure*": figure,
+	}
+}
+
+func getImageRawArgCommands() map[string]rawArgCommand {
+	return map[string]rawArgCommand{
+		"includegraphics": {optArg: true, html: true, handler: handleIncludeGraphics},
+	}
+}
+
+// file extensions tried in order when \includegraphics omits the extension, as LaTeX does
+var imageExtensions = []string{".png", ".jpg", ".jpeg", ".gif", ".svg"}
+
+func handleIncludeGraphics(info *latexTransformationInfo, opt string, arg string) error {
+	name, fullPath, found, err := resolveImagePath(info.options.SourceFile, strings.TrimSpace(arg))
+	if err != nil {
+		return err
+	}
+	if !found {
+		info.log("Image file not found: " + name)
+	}
+	src := filepath.ToSlash(name)
+	if info.options.EmbedImages && found {
+		fileName, err := info.embedFile(fullPath)
+		if err != nil {
+			return errors.New("could not read image " + name + ": " + err.Error())
+		}
+		src = "@@PLUGINFILE@@/" + url.PathEscape(fileName)
+		info.log("Embedded image " + fileName)
+	}
+	img := "<img" + formatAttribute("src", src) + formatAttribute("alt", filepath.Base(name))
+	img += imageSizeAttributes(info, opt, fullPath, found)
+	img += ">"
+	info.log("Replaced \\includegraphics with <img>")
+	info.addRawToOutputString(img)
+	return nil
+}
+
+// embedFile attaches the file at fullPath once and returns its name. A name that is already
+// taken by another file gets a number, like plot-2.png.
+func (l *latexTransformationInfo) embedFile(fullPath string) (string, error) {
+	if name, ok := l.embeddedNames[fullPath]; ok {
+		return name, nil
+	}
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return "", err
+	}
+	base := filepath.Base(fullPath)
+	name := base
+	for number := 2; l.isEmbeddedName(name); number++ {
+		ext := filepath.Ext(base)
+		name = strings.TrimSuffix(base, ext) + "-" + strconv.Itoa(number) + ext
+	}
+	l.embeddedNames[fullPath] = name
+	l.files = append(l.files, EmbeddedFile{name, "/", base64.StdEncoding.EncodeToString(content)})
+	return name, nil
+}
+
+func (l *latexTransformationInfo) isEmbeddedName(name string) bool {
+	for _, file := range l.files {
+		if file.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveImagePath finds an image relative to the source file, trying the usual extensions if it
+// has none. Absolute paths and paths that leave the directory of the source file are rejected.
+func resolveImagePath(sourceFile string, name string) (string, string, bool, error) {
+	if err := checkContained("image", name, "the directory of the source file"); err != nil {
+		return "", "", false, err
+	}
+	candidates := []string{name}
+	if filepath.Ext(name) == "" {
+		candidates = []string{}
+		for _, ext := range imageExtensions {
+			candidates = append(candidates, name+ext)
+		}
+	}
+	for _, candidate := range candidates {
+		fullPath := filepath.Join(filepath.Dir(sourceFile), filepath.FromSlash(candidate))
+		if stat, err := os.Stat(fullPath); err == nil && !stat.IsDir() {
+			return candidate, fullPath, true, nil
+		}
+	}
+	return name, "", false, nil
+}
+
+func imageSizeAttributes(info *latexTransformationInfo, opt string, fullPath string, found bool) string {
+	attrs := ""
+	style := ""
+	for _, option := range strings.Split(opt, ",") {
+		key, value, _ := strings.Cut(option, "=")
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		switch key {
+		case "width", "height":
+			if px, ok := lengthToPixels(value); ok {
+				attrs += formatAttribute(key, strconv.Itoa(px))
+			} else if percent, ok := relativeLength(value, key); ok {
+				style += key + ":" + percent + "%;"
+			} else {
+				info.log("Ignored image " + key + " " + value)
+			}
+		case "scale":
+			scale, err := strconv.ParseFloat(value, 64)
+			width, height, ok := imageDimensions(fullPath, found)
+			if err != nil || !ok {
+				info.log("Ignored image scale " + value)
+				continue
+			}
+			attrs += formatAttribute("width", strconv.Itoa(int(math.Round(float64(width)*scale))))
+			attrs += formatAttribute("height", strconv.Itoa(int(math.Round(float64(height)*scale))))
+		}
+	}
+	if style != "" {
+		attrs += formatAttribute("style", style)
+	}
+	return attrs
+}
+
+// pixels per unit at 96 dpi, em and ex assume the usual 16px browser font
+var pixelsPerUnit = map[string]float64{
+	"px": 1,
+	"pt": 96 / 72.27,
+	"bp": 96 / 72.0,
+	"pc": 12 * 96 / 72.27,
+	"in": 96,
+	"cm": 96 / 2.54,
+	"mm": 96 / 25.4,
+	"em": 16,
+	"ex": 8,
+}
+
+func lengthToPixels(length string) (int, bool) {
+	for unit, factor := range pixelsPerUnit {
+		number, found := strings.CutSuffix(length, unit)
+		if !found {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+		if err != nil {
+			return 0, false
+		}
+		return int(math.Round(value * factor)), true
+	}
+	return 0, false
+}
+
+func relativeLength(length string, key string) (string, bool) {
+	references := []string{"\\textwidth", "\\linewidth", "\\columnwidth"}
+	if key == "height" {
+		references = []string{"\\textheight"}
+	}
+	for _, reference := range references {
+		number, found := strings.CutSuffix(length, reference)
+		if !found {
+			continue
+		}
+		factor := 1.0
+		if strings.TrimSpace(number) != "" {
+			value, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+			if err != nil {
+				return "", false
+			}
+			factor = value
+		}
+		return strconv.FormatFloat(factor*100, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+func imageDimensions(fullPath string, found bool) (int, int, bool) {
+	if !found {
+		return 0, 0, false
+	}
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return 0, 0, false
+	}
+	defer file.Close()
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, 0, false
+	}
+	return config.Width, config.Height, true
+}
+
+// MoodleQuestionText returns the transformed text and the embedded files as the questiontext
+// element of a Moodle XML question.
+func (r Result) MoodleQuestionText() string {
+	var text strings.Builder
+	text.WriteString("<questiontext format=\"html\">\n")
+	text.WriteString("<text><![CDATA[" + strings.ReplaceAll(r.Transformed, "]]>", "]]]]><![CDATA[>") + "]]></text>\n")
+	for _, file := range r.Files {
+		text.WriteString(file.MoodleXML() + "\n")
+	}
+	text.WriteString("</questiontext>\n")
+	return text.String()
+}
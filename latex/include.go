@@ -0,0 +1,110 @@
+package latex
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+func getIncludeRawArgCommands() map[string]rawArgCommand {
+	return map[string]rawArgCommand{
+		"input":   {handler: handleInclude},
+		"include": {handler: handleInclude},
+		"subfile": {handler: handleInclude},
+	}
+}
+
+// handleInclude transforms the included file in place of the command. Positions inside it name the file.
+func handleInclude(info *latexTransformationInfo, opt string, arg string) error {
+	command := info.rawArgs.command
+	name, path, err := resolveInclude(info.options.RootDir, strings.TrimSpace(arg))
+	if err != nil {
+		return err
+	}
+	if slices.Contains(info.includeStack, name) {
+		return errors.New("include cycle: " + strings.Join(append(info.includeStack, name), " -> "))
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return errors.New("could not read " + name + ": " + err.Error())
+	}
+	body, start := string(content), Position{Line: 1, Column: 1, File: name}
+	if command == "subfile" {
+		body, start = subfileBody(body, name)
+	}
+	info.log("Inlined \\" + command + "{" + name + "}")
+	outer := info.pos
+	info.pos = start
+	info.includeStack = append(info.includeStack, name)
+	reader := newInputNormalizer(strings.NewReader(body), info)
+	for {
+		char, _, err := reader.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := info.step(char); err != nil {
+			return err
+		}
+	}
+	// like the end of a line, the end of the file ends a control word
+	if !strings.HasSuffix(body, "\n") {
+		if err := info.step(' '); err != nil {
+			return err
+		}
+	}
+	info.includeStack = info.includeStack[:len(info.includeStack)-1]
+	info.pos = outer
+	return nil
+}
+
+// resolveInclude finds an included file relative to root, trying the .tex extension first like LaTeX.
+// Absolute paths and paths that leave root are rejected.
+func resolveInclude(root string, arg string) (string, string, error) {
+	if err := checkContained("included file", arg, "the root directory"); err != nil {
+		return "", "", err
+	}
+	for _, candidate := range []string{arg + ".tex", arg} {
+		path := filepath.Join(root, filepath.FromSlash(candidate))
+		if stat, err := os.Stat(path); err == nil && !stat.IsDir() {
+			return filepath.ToSlash(filepath.Clean(candidate)), path, nil
+		}
+	}
+	return "", "", errors.New("included file " + arg + " not found")
+}
+
+// checkContained rejects a path from the input that is absolute or leaves the directory it is
+// resolved in, so a document cannot read arbitrary files.
+func checkContained(kind string, arg string, directory string) error {
+	name := filepath.Clean(filepath.FromSlash(arg))
+	if filepath.IsAbs(name) || strings.HasPrefix(arg, "/") || filepath.VolumeName(name) != "" {
+		return errors.New(kind + " " + arg + " must be relative to " + directory)
+	}
+	if name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return errors.New(kind + " " + arg + " is outside " + directory)
+	}
+	return nil
+}
+
+// subfileBody returns the document body of a subfile and where it starts.
+func subfileBody(content string, name string) (string, Position) {
+	start := Position{Line: 1, Column: 1, File: name}
+	index := strings.Index(content, documentBeginMarker)
+	if index < 0 {
+		return content, start
+	}
+	before := content[:index+len(documentBeginMarker)]
+	body := content[len(before):]
+	if end := strings.Index(body, "\\end{document}"); end >= 0 {
+		body = body[:end]
+	}
+	start.Line += strings.Count(before, "\n")
+	start.Column += utf8.RuneCountInString(before[strings.LastIndex(before, "\n")+1:])
+	return body, start
+}
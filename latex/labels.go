@@ -0,0 +1,238 @@
+package latex
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	duplicateLabelRule     = "duplicate-label"
+	undefinedReferenceRule = "undefined-reference"
+)
+
+// references are written as placeholders and resolved once all labels are known
+const (
+	referenceStart = "\ue000"
+	referenceEnd   = "\ue001"
+)
+
+type reference struct {
+	label string
+	eq    bool
+	pos   Position
+}
+
+type equationState struct {
+	environ     string
+	rowNumbered bool
+	rowTagged   bool
+}
+
+func GetNumberedMathEnvirons() map[string]bool {
+	return map[string]bool{
+		"equation": true,
+		"align":    true,
+		"gather":   true,
+		"multline": true,
+		"alignat":  true,
+		"flalign":  true,
+	}
+}
+
+func getMultiRowMathEnvirons() map[string]bool {
+	return map[string]bool{
+		"align":   true,
+		"gather":  true,
+		"alignat": true,
+		"flalign": true,
+	}
+}
+
+func getLabelRawArgCommands() map[string]rawArgCommand {
+	return map[string]rawArgCommand{
+		"label": {handler: handleLabel},
+		"ref":   {handler: handleRef},
+		"eqref": {handler: handleRef},
+		"tag":   {handler: handleTag},
+		"tag*":  {handler: handleTag},
+	}
+}
+
+func handleLabel(info *latexTransformationInfo, opt string, arg string) error {
+	label := strings.TrimSpace(arg)
+	if _, exists := info.labels[label]; exists {
+		info.diagnose(duplicateLabelRule, info.tokenPos, "label "+label+" is defined more than once")
+	}
+	info.labels[label] = info.currentLabel
+	if info.equation.environ != "" && info.equation.rowNumbered && !info.equation.rowTagged {
+		info.log("Replaced \\label in numbered equation with \\tag")
+		info.addRawToOutputString("\\tag{" + info.currentLabel + "}")
+		info.equation.rowTagged = true
+	} else {
+		info.log("Removed \\label")
+	}
+	return nil
+}
+
+// handleTag keeps \tag for MathJax and makes its argument the number of the labels in the row.
+func handleTag(info *latexTransformationInfo, opt string, arg string) error {
+	info.currentLabel = strings.TrimSpace(arg)
+	info.addRawToOutputString("\\" + info.rawArgs.command + "{" + arg + "}")
+	return nil
+}
+
+func handleRef(info *latexTransformationInfo, opt string, arg string) error {
+	eq := info.rawArgs.command == "eqref"
+	info.references = append(info.references, reference{strings.TrimSpace(arg), eq, info.tokenPos})
+	info.log("Resolved \\" + info.rawArgs.command)
+	info.addRawToOutputString(referenceStart + strconv.Itoa(len(info.references)-1) + referenceEnd)
+	return nil
+}
+
+// resolveReferences replaces the reference placeholders in s with the label numbers.
+func (l *latexTransformationInfo) resolveReferences(s string) string {
+	if len(l.references) == 0 {
+		return s
+	}
+	var resolved strings.Builder
+	for {
+		before, rest, found := strings.Cut(s, referenceStart)
+		resolved.WriteString(before)
+		if !found {
+			break
+		}
+		index, after, _ := strings.Cut(rest, referenceEnd)
+		s = after
+		i, err := strconv.Atoi(index)
+		if err != nil || i >= len(l.references) {
+			continue
+		}
+		ref := l.references[i]
+		number, ok := l.labels[ref.label]
+		if !ok {
+			l.diagnose(undefinedReferenceRule, ref.pos, "reference to undefined label "+ref.label)
+			number = "??"
+		} else if number == "" {
+			l.diagnose(undefinedReferenceRule, ref.pos, "label "+ref.label+" is not in a numbered environment")
+			number = "??"
+		}
+		if ref.eq {
+			number = "(" + number + ")"
+		}
+		resolved.WriteString(number)
+	}
+	return resolved.String()
+}
+
+func (l *latexTransformationInfo) startEquation(environ string) {
+	if !GetNumberedMathEnvirons()[environ] {
+		return
+	}
+	l.equation = equationState{environ: environ}
+	l.startEquationRow()
+}
+
+func (l *latexTransformationInfo) startEquationRow() {
+	l.equation.rowNumbered = true
+	l.equation.rowTagged = false
+	l.currentLabel = strconv.Itoa(l.equationCounter + 1)
+}
+
+// finishEquationRow counts a numbered row. With Options.TagEquations a row without label is
+// tagged, so MathJax shows the same numbers as LaTeX.
+func (l *latexTransformationInfo) finishEquationRow() {
+	if !l.equation.rowNumbered {
+		return
+	}
+	l.equationCounter += 1
+	if l.options.TagEquations && !l.equation.rowTagged {
+		l.log("Numbered equation with \\tag")
+		l.addRawToOutputString("\\tag{" + strconv.Itoa(l.equationCounter) + "}")
+	}
+}
+
+func (l *latexTransformationInfo) finishEquation() {
+	if l.equation.environ == "" {
+		return
+	}
+	l.finishEquationRow()
+	l.equation = equationState{}
+}
+
+// equationNewline starts a new numbered row when \\ ends a row of a multi-line equation.
+func (l *latexTransformationInfo) equationNewline() {
+	env, _ := l.getCurrEnv()
+	if l.equation.environ != "" && env == l.equation.environ && getMultiRowMathEnvirons()[env] {
+		l.finishEquationRow()
+		l.startEquationRow()
+	}
+}
+
+func (l *latexTransformationInfo) openNumberedEnviron(environ string) {
+	if environ == "enumerate" {
+		l.itemCounters = append(l.itemCounters, 0)
+	}
+}
+
+func (l *latexTransformationInfo) closeNumberedEnviron(environ string) {
+	if environ == "enumerate" && len(l.itemCounters) > 0 {
+		l.itemCounters = l.itemCounters[0 : len(l.itemCounters)-1]
+	}
+}
+
+func (l *latexTransformationInfo) trackNumbering(command string) {
+	switch command {
+	case "nonumber", "notag", "tag", "tag*":
+		l.equation.rowNumbered = false
+	case "item":
+		env, _ := l.getCurrEnv()
+		if env != "enumerate" || len(l.itemCounters) == 0 {
+			return
+		}
+		l.itemCounters[len(l.itemCounters)-1] += 1
+		label := ""
+		for depth, count := range l.itemCounters {
+			if depth == 0 {
+				label += itemLabel(depth, count)
+			} else {
+				label += "(" + itemLabel(depth, count) + ")"
+			}
+		}
+		l.currentLabel = label
+	}
+}
+
+// itemLabel formats an item number like the list types used for enumerate: a, i, A.
+func itemLabel(depth int, count int) string {
+	switch depth {
+	case 0:
+		return alphabetic(count, 'a')
+	case 1:
+		return roman(count)
+	default:
+		return alphabetic(count, 'A')
+	}
+}
+
+func alphabetic(count int, first rune) string {
+	label := ""
+	for count > 0 {
+		count -= 1
+		label = string(first+rune(count%26)) + label
+		count /= 26
+	}
+	return label
+}
+
+func roman(count int) string {
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	numerals := []string{"m", "cm", "d", "cd", "c", "xc", "l", "xl", "x", "ix", "v", "iv", "i"}
+	label := ""
+	for i, value := range values {
+		for count >= value {
+			label += numerals[i]
+			count -= value
+		}
+	}
+	return label
+}
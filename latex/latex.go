@@ -1,15 +1,58 @@
 package latex
 
 import (
+	"bufio"
+	"bytes"
 	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 	"strconv"
 )
 
-func TransformLatex(latex string) latexTransFormResult {
-	original := latex + " "
-	info := latexTransformationInfo{
-		current_string: "",
+func TransformLatex(latex string) Result {
+	return defaultTransformer().TransformString(latex)
+}
+
+func TransformLatexWithOptions(latex string, options Options) Result {
+	return NewTransformer(DefaultRules(), options).TransformString(latex)
+}
+
+// Transform reads LaTeX from r and writes the transformed text to w, see Transformer.Transform.
+func Transform(r io.Reader, w io.Writer, options Options) (Report, error) {
+	return NewTransformer(DefaultRules(), options).Transform(r, w)
+}
+
+func (l *latexTransformationInfo) transform(r io.Reader, w io.Writer) (Report, error) {
+	err := runTransformation(bufio.NewReader(r), l)
+	if err != nil {
+		return Report{Diagnostics: l.diagnostics}, errors.New(l.pos.String() + ": " + err.Error())
+	}
+	preamble := l.preamble()
+	transformed := l.outputReplacer().Replace(l.resolveReferences(l.output.String() + l.footnoteList()))
+	if l.html || l.htmlWritten {
+		l.validateOutput(preamble + transformed)
+	}
+	report := Report{
+		Log: logEntries(l.logMap),
+		Files: l.files,
+		Diagnostics: l.diagnostics,
+	}
+	if l.html {
+		report.Info = l.profile.Info
+	}
+	if _, err := io.WriteString(w, preamble); err != nil {
+		return report, err
+	}
+	_, err = io.WriteString(w, transformed)
+	return report, err
+}
+
+func (t *Transformer) newTransformationInfo() *latexTransformationInfo {
+	return &latexTransformationInfo{
 		openBraces: 0,
 		mode: notOpen,
 		envMode: noMathEnv,
@@ -18,39 +61,114 @@ func TransformLatex(latex string) latexTransFormResult {
 			tokenInfo: "",
 		},
 		commands: commandHandling{
-			customCommands: GetCustomCommands(),
-			commandReplacements: GetCommandReplacements(),
+			customCommands: t.rules.CustomCommands,
+			customCommandOrder: t.rules.CustomCommandOrder,
+			customCommandDependencies: t.rules.CustomCommandDependencies,
+			commandReplacements: t.rules.CommandReplacements,
 			usedCustomCommands: make(map[string]bool),
 		},
-		knownMathEnvirons: GetKnownMathEnvirons(),
+		knownMathEnvirons: t.rules.KnownMathEnvirons,
 		braceReplacement: make([]braceClosingData, 0),
 		environmentStack: make([]string, 0),
-		environmentReplacements: GetEnvReplacements(),
+		environmentReplacements: t.rules.EnvReplacements,
 		bracketReplacement: nil,
-		html: false,
+		html: t.options.HTML == HTMLAlways,
 		logMap: make(map[string]int),
+		options: t.options,
+		rawArgCommands: t.rawArgCommands,
+		files: make([]EmbeddedFile, 0),
+		embeddedNames: make(map[string]string),
+		theoremNames: t.theoremNames,
+		verbatimEnvirons: t.rules.VerbatimEnvirons,
+		commentEnvirons: t.rules.CommentEnvirons,
+		flags: maps.Clone(t.flags),
+		conditionals: make([]conditional, 0),
+		textAccents: t.rules.TextAccents,
+		textSymbols: t.rules.TextSymbols,
+		germanShorthands: t.germanShorthands,
+		pos: Position{Line: 1, Column: 1},
+		diagnostics: make([]Diagnostic, 0),
+		mathjaxCommands: t.rules.MathJaxCommands,
+		mathjaxEnvirons: t.rules.MathJaxEnvirons,
+		rules: make(map[string]Severity),
+		mathOnlyCommands: t.rules.MathOnlyCommands,
+		mathSymbols: t.rules.MathSymbols,
+		labels: make(map[string]string),
+		references: make([]reference, 0),
+		itemCounters: make([]int, 0),
+		footnotes: make([]string, 0),
+		profile: t.profile,
 	}
-	info.setHtmlIfNeeded(original)
-	for _, char := range original {
-		handleCharacter(char, &info)
+}
+
+// step transforms one character, the position only advances past characters without error.
+func (l *latexTransformationInfo) step(char rune) error {
+	err := handleCharacter(char, l)
+	if err == nil {
+		err = l.err
 	}
-	prelude_string := info.preamble()
-	logString := ""
-	for key, val := range info.logMap {
-		logString += strconv.Itoa(val) + "x " + string(key) + "\n"
+	if err == nil {
+		l.advancePosition(char)
 	}
-	infoStr := ""
-	if info.html {
-		infoStr = "Output contains HTML.\nInput in Moodle as source code (Ansicht -> Quellcode)!"
+	return err
+}
+
+// runTransformation transforms the runes of reader followed by a sentinel space, which is removed from the output again.
+func runTransformation(reader io.RuneReader, info *latexTransformationInfo) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("internal error: %v", r)
+		}
+	}()
+	reader = newInputNormalizer(reader, info)
+	if info.options.Preamble != "" {
+		info.harvestPreamble(info.options.Preamble)
 	}
-	return latexTransFormResult{
-		prelude_string + info.current_string[0:len(info.current_string) - 1],
-		[]string{},
-		true,
-		"",
-		logString,
-		infoStr,
+	reader, err = readPreamble(reader, info)
+	if err != nil {
+		return err
 	}
+	for !info.documentEnded {
+		char, _, err := reader.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := info.step(char); err != nil {
+			return err
+		}
+	}
+	outputLength := info.output.Len()
+	if err := info.step(' '); err != nil {
+		return err
+	}
+	if info.output.Len() > outputLength && bytes.HasSuffix(info.output.Bytes(), []byte(" ")) {
+		info.output.Truncate(info.output.Len() - 1)
+	}
+	switch info.getTokenType() {
+	case comment:
+		finishComment(info, strings.TrimSuffix(info.body.String(), " "), false)
+	case verbatimBody:
+		// the rest of the input was read as the body
+		info.diagnose(unclosedRule, info.verbatim.start, "environment "+info.verbatim.environ+" is not closed")
+	case verbInline:
+		info.diagnose(unclosedRule, info.verbatim.start, "\\verb is not closed")
+	case theoremTitle:
+		environ, _ := info.getCurrEnv()
+		info.diagnose(unclosedRule, info.pos, "title of environment "+environ+" is not closed")
+	}
+	if env, open := info.getCurrEnv(); open {
+		info.diagnose(unclosedRule, info.pos, "environment "+env+" is not closed")
+	}
+	if info.isMathModeActive() {
+		info.diagnose(unclosedRule, info.pos, "math mode is not closed")
+	}
+	for _, open := range info.conditionals {
+		info.diagnose(unclosedRule, open.start, "conditional \\"+open.command+" is not closed")
+	}
+	return nil
 }
 
 func braceCheck(info *latexTransformationInfo, char rune) bool {
@@ -69,6 +187,9 @@ func braceCheck(info *latexTransformationInfo, char rune) bool {
 					info.addRawToOutputString(action.replacement)
 				}
 				info.popClosingBraceAction()
+				if action.onClose != nil {
+					action.onClose(info)
+				}
 				return true
 			}
 		}
@@ -88,12 +209,40 @@ func braceCheck(info *latexTransformationInfo, char rune) bool {
 
 func checkForComment(info *latexTransformationInfo, char rune) {
 	if char == '%' {
-		info.log("Removed comment")
 		info.setPrevToken(token{comment, ""})
+		info.body.Reset()
+	}
+}
+
+func handlePrevComment(char rune, info *latexTransformationInfo) {
+	if char != '\n' {
+		info.body.WriteRune(char)
+		return
+	}
+	finishComment(info, info.body.String(), true)
+}
+
+func finishComment(info *latexTransformationInfo, comment string, newline bool) {
+	info.setPrevToken(token{none, ""})
+	switch {
+	case info.options.Comments == CommentsRemove:
+		info.log("Removed comment")
+	case info.options.Comments == CommentsHTML && !info.isMathContext():
+		info.log("Replaced comment with <!-- -->")
+		info.addRawToOutputString("<!--" + strings.ReplaceAll(comment, "--", "- -") + "-->")
+	default:
+		info.log("Kept comment")
+		info.addToOutputString("%" + comment)
+		if newline {
+			info.addToOutputString("\n")
+		}
 	}
 }
 
 func handleCharacter(char rune, info *latexTransformationInfo) error {
+	if info.isSkipping() {
+		return handleSkippedChar(char, info)
+	}
 	switch info.getTokenType() {
 	case dollar:
 		err := handlePrevDollarChar(char, info)
@@ -101,6 +250,9 @@ func handleCharacter(char rune, info *latexTransformationInfo) error {
 			return err
 		}
 	case none:
+		if info.hardBreak && !startLineAfterBreak(char, info) {
+			return nil
+		}
 		err := handlePrevNoTokenChar(char, info)
 		if err != nil {
 			return err
@@ -111,9 +263,7 @@ func handleCharacter(char rune, info *latexTransformationInfo) error {
 			return err
 		}
 	case comment:
-		if char == '\n' {
-			info.setPrevToken(token{none, ""})
-		}
+		handlePrevComment(char, info)
 	case backslashOngoing:
 		err := handlePrevBackslashOngoing(char, info)
 		if err != nil {
@@ -129,34 +279,88 @@ func handleCharacter(char rune, info *latexTransformationInfo) error {
 		if err != nil {
 			return err
 		}
+	case rawArgs:
+		err := handlePrevRawArgs(char, info)
+		if err != nil {
+			return err
+		}
+	case envOptArg:
+		err := handlePrevEnvOptArg(char, info)
+		if err != nil {
+			return err
+		}
+	case theoremTitle:
+		err := handlePrevTheoremTitle(char, info)
+		if err != nil {
+			return err
+		}
+	case verbatimBody:
+		err := handlePrevVerbatimBody(char, info)
+		if err != nil {
+			return err
+		}
+	case verbInline:
+		handlePrevVerbInline(char, info)
+	case newifDecl:
+		err := handlePrevNewifDecl(char, info)
+		if err != nil {
+			return err
+		}
+	case accentArg:
+		err := handlePrevAccentArg(char, info)
+		if err != nil {
+			return err
+		}
+	case textSymbolEnd:
+		err := handlePrevTextSymbolEnd(char, info)
+		if err != nil {
+			return err
+		}
+	case ligature:
+		err := handlePrevLigature(char, info)
+		if err != nil {
+			return err
+		}
+	case babelShorthand:
+		err := handlePrevBabelShorthand(char, info)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+var mathClosing = map[rune]rune{'(': ')', '[': ']'}
+
 func handlePrevDollarChar(char rune, info *latexTransformationInfo) error {
 	info.setPrevToken(token{none, ""})
 	switch char {
 	case '$':
 		switch info.getMathMode() {
 		case notOpen:
+			info.diagnose(dollarDisplayRule, info.tokenPos, "$$ display math, use \\[...\\]")
 			info.setMathMode(block)
-			info.addToOutputString("\\[")
-			info.log("Replaced $...$ with \\[...\\]")
+			open, close := info.displayDelimiters()
+			info.addToOutputString(open)
+			info.log("Replaced $...$ with " + open + "..." + close)
 		case inline:
 			return errors.New("math error: $$ after open $")
 		case block:
 			info.setMathMode(notOpen)
-			info.addToOutputString("\\]")
+			_, close := info.displayDelimiters()
+			info.addToOutputString(close)
 		}
 	default:
 		switch info.getMathMode() {
 		case notOpen:
 			info.setMathMode(inline)
-			info.addToOutputString("\\(")
-			info.log("Replaced $...$ with \\(...\\)")
+			open, close := info.inlineDelimiters()
+			info.addToOutputString(open)
+			info.log("Replaced $...$ with " + open + "..." + close)
 		case inline:
 			info.setMathMode(notOpen)
-			info.addToOutputString("\\)")
+			_, close := info.inlineDelimiters()
+			info.addToOutputString(close)
 		case block:
 			return errors.New("math error: $ after open $$")
 		}
@@ -165,13 +369,16 @@ func handlePrevDollarChar(char rune, info *latexTransformationInfo) error {
 			info.setPrevToken(token{backslash, ""})
 		default:
 			checkForComment(info, char)
-			handleCharacter(char, info)
+			return handleCharacter(char, info)
 		}
 	}
 	return nil
 }
 
 func handlePrevNoTokenChar(char rune, info *latexTransformationInfo) error {
+	if char != '\\' {
+		info.lintText(char)
+	}
 	switch char {
 	case '$':
 		info.setPrevToken(token{dollar, ""})
@@ -179,7 +386,28 @@ func handlePrevNoTokenChar(char rune, info *latexTransformationInfo) error {
 		info.setPrevToken(token{backslash, ""})
 	case '%':
 		checkForComment(info, char)
+	case '-', '`', '\'':
+		if info.isTextMode() {
+			info.setPrevToken(token{ligature, string(char)})
+		} else {
+			info.addToOutputString(string(char))
+		}
+	case '"':
+		if info.isTextMode() && info.germanShorthands != nil {
+			info.setPrevToken(token{babelShorthand, ""})
+		} else {
+			info.addToOutputString(string(char))
+		}
+	case '~':
+		if info.isTextMode() {
+			handleTilde(info)
+		} else {
+			info.addToOutputString(string(char))
+		}
 	default:
+		if replaceMathSymbol(char, info) {
+			return nil
+		}
 		action := braceCheck(info, char)
 		if !action {
 			info.addToOutputString(string(char))
@@ -191,9 +419,16 @@ func handlePrevNoTokenChar(char rune, info *latexTransformationInfo) error {
 func handlePrevBackslash(char rune, info *latexTransformationInfo) error {
 	if char == '\\' {
 		if !info.isMathModeActive() && info.getEnv() == noMathEnv {
-			info.log("Wrapped newline \\\\ in \\( \\)")
-			info.addToOutputString("\\(\\\\ \\)")
+			info.diagnose(textNewlineRule, info.tokenPos, "\\\\ outside of math, use an empty line or an HTML element")
+		}
+		if !info.isMathModeActive() && info.getEnv() == noMathEnv && info.isMarkdown() {
+			handleMarkdownLineBreak(info)
+		} else if !info.isMathModeActive() && info.getEnv() == noMathEnv {
+			open, close := info.inlineDelimiters()
+			info.log("Wrapped newline \\\\ in " + open + " " + close)
+			info.addToOutputString(open + "\\\\ " + close)
 		} else {
+			info.equationNewline()
 			info.addToOutputString("\\\\")
 		}
 		info.setPrevToken(token{none, ""})
@@ -205,13 +440,18 @@ func handlePrevBackslash(char rune, info *latexTransformationInfo) error {
 			info.addToOutputString("\\" + string(char))
 		case '(', '[':
 			if !info.isMathModeActive() {
+				open, close := info.inlineDelimiters()
 				if char == '(' {
 					info.setMathMode(inline)
 				} else {
 					info.setMathMode(block)
+					open, close = info.displayDelimiters()
 				}
 				info.setPrevToken(token{none, ""})
-				info.addToOutputString("\\" + string(char))
+				if open != "\\" + string(char) {
+					info.log("Replaced \\" + string(char) + "...\\" + string(mathClosing[char]) + " with " + open + "..." + close)
+				}
+				info.addToOutputString(open)
 			} else {
 				return errors.New("unexpected math mode opening \\" + string(char) + " in math mode")
 			}
@@ -225,19 +465,23 @@ func handlePrevBackslash(char rune, info *latexTransformationInfo) error {
 					}
 					info.setMathMode(notOpen)
 					info.setPrevToken(token{none, ""})
-					info.addToOutputString("\\" + string(char))
+					_, close := info.inlineDelimiters()
+					info.addToOutputString(close)
 				} else {
 					if info.getMathMode() == inline {
 						return errors.New("mismatched closure of math mode: \\]")
 					}
 					info.setMathMode(notOpen)
 					info.setPrevToken(token{none, ""})
-					info.addToOutputString("\\" + string(char))
+					_, close := info.displayDelimiters()
+					info.addToOutputString(close)
 				}
 			}
 		default:
 			if unicode.IsLetter(char) {
 				info.setPrevToken(token{backslashOngoing, string(char)})
+			} else if isNonLetterAccent(char) && info.isTextMode() {
+				startAccent(info, string(char))
 			} else {
 				info.addToOutputString("\\" + string(char))
 				info.setPrevToken(token{none, ""})
@@ -248,13 +492,49 @@ func handlePrevBackslash(char rune, info *latexTransformationInfo) error {
 }
 
 func handlePrevBackslashOngoing(char rune, info *latexTransformationInfo) error {
-	if unicode.IsLetter(char) {
+	// control words in math end at the first character that is not an ASCII letter, such as α
+	letter := unicode.IsLetter(char) && (!info.isMathContext() || char < utf8.RuneSelf)
+	if letter && !strings.HasSuffix(info.getTokenInfo(), "*") {
+		info.addTokenInfo(string(char))
+	} else if _, starred := info.getCommandReplacement(info.getTokenInfo() + "*"); char == '*' && starred {
+		info.addTokenInfo(string(char))
+	} else if _, starred := info.getRawArgCommand(info.getTokenInfo() + "*"); char == '*' && starred {
 		info.addTokenInfo(string(char))
 	} else {
-		var repl commandReplacement = commandReplacement{}
+		var repl CommandReplacement = CommandReplacement{}
 		repl_valid := false
 		command := string(info.getTokenInfo())
 		oldCommand := command
+		if handled, err := handleConditionalCommand(command, char, info); handled {
+			return err
+		}
+		info.checkMathCommand(command)
+		info.lintCommand(command)
+		info.trackNumbering(command)
+		if _, accent := info.getTextAccent(command); accent && info.isTextMode() {
+			startAccent(info, command)
+			return handleCharacter(char, info)
+		}
+		if _, symbol := info.getTextSymbol(command); symbol && info.isTextMode() {
+			return handleTextSymbol(command, char, info)
+		}
+		if command == "footnote" {
+			return handleFootnote(char, info)
+		}
+		if environ, _ := info.getCurrEnv(); command == "item" && info.isMarkdownList(environ) {
+			return handleMarkdownItem(char, info)
+		}
+		if command == "verb" {
+			info.verbatim = verbatimState{start: info.tokenPos}
+			info.setPrevToken(token{verbInline, ""})
+			info.body.Reset()
+			handlePrevVerbInline(char, info)
+			return nil
+		}
+		if _, raw := info.getRawArgCommand(command); raw {
+			info.startRawArgs(command)
+			return handleCharacter(char, info)
+		}
 		_, contains := info.customCommands()[command]
 		if contains {
 			info.addCommandUsage(command)
@@ -263,16 +543,16 @@ func handlePrevBackslashOngoing(char rune, info *latexTransformationInfo) error
 			repl = replac
 			if ok {
 				repl_valid = true
-				if repl.argCommand {
+				if repl.ArgCommand {
 					if char != '{' {
 						return errors.New("expected { for command "+ string(command))
 					}
-				} else if repl.optArgCommand {
+				} else if repl.OptArgCommand {
 					if char != '[' {
 						return errors.New("expected [ for command "+ string(command))
 					}
-				} else {
-					command = repl.leftRepl[1:]
+				} else if repl.LeftRepl != "" {
+					command = repl.LeftRepl[1:]
 				}
 			}
 		}
@@ -284,32 +564,34 @@ func handlePrevBackslashOngoing(char rune, info *latexTransformationInfo) error
 			info.setPrevToken(token{environClose, ""})
 		} else {
 			if repl_valid {
-				if repl.argCommand {
-					if repl.rightRepl != "" {
-						info.log("Replaced \\" + string(oldCommand) + "{...} with " + repl.leftRepl + "..." + repl.rightRepl)
+				if repl.ArgCommand {
+					if repl.RightRepl != "" {
+						info.log("Replaced \\" + string(oldCommand) + "{...} with " + repl.LeftRepl + "..." + repl.RightRepl)
 					} else {
-						info.log("Replaced \\" + string(oldCommand) + "{...} with " + repl.leftRepl)
+						info.log("Replaced \\" + string(oldCommand) + "{...} with " + repl.LeftRepl)
 					}
-				} else if repl.optArgCommand {
-					if repl.rightRepl != "" {
-						info.log("Replaced \\" + string(oldCommand) + "[...] with " + repl.leftRepl +"..." + repl.rightRepl)
+				} else if repl.OptArgCommand {
+					if repl.RightRepl != "" {
+						info.log("Replaced \\" + string(oldCommand) + "[...] with " + repl.LeftRepl +"..." + repl.RightRepl)
 					} else {
-						info.log("Replaced \\" + string(oldCommand) + "[...] with" + repl.leftRepl)
+						info.log("Replaced \\" + string(oldCommand) + "[...] with" + repl.LeftRepl)
 					}
+				} else if repl.LeftRepl == "" {
+					info.log("Removed \\" + string(oldCommand))
 				} else {
-					info.log("Replaced \\" + string(oldCommand) + " with " + repl.leftRepl)
+					info.log("Replaced \\" + string(oldCommand) + " with " + repl.LeftRepl)
 				}
-				if repl.escapeRepl {
-					info.addToOutputString(repl.leftRepl)
+				if repl.EscapeRepl {
+					info.addToOutputString(repl.LeftRepl)
 				} else {
-					info.addRawToOutputString(repl.leftRepl)
+					info.addRawToOutputString(repl.LeftRepl)
 				}
 				info.setPrevToken(token{none, ""})
-				if repl.argCommand {
-					info.pushClosingBraceAction(braceClosingData{repl.escapeRepl, info.getOpenBraces(), repl.rightRepl})
+				if repl.ArgCommand {
+					info.pushClosingBraceAction(braceClosingData{repl.EscapeRepl, info.getOpenBraces(), repl.RightRepl, nil})
 					braceCheck(info, char)
-				} else if repl.optArgCommand {
-					info.setBracketReplacement(bracketClosingData{repl.escapeRepl, repl.rightRepl})
+				} else if repl.OptArgCommand {
+					info.setBracketReplacement(bracketClosingData{repl.EscapeRepl, repl.RightRepl})
 					braceCheck(info, char)
 				} else {
 					err := handleCharacter(char, info)
@@ -320,6 +602,9 @@ func handlePrevBackslashOngoing(char rune, info *latexTransformationInfo) error
 			} else {
 				info.addToOutputString("\\" + command)
 				info.setPrevToken(token{none, ""})
+				if char == '{' && mathTextCommands[command] && info.isMathContext() {
+					info.startMathText()
+				}
 				err := handleCharacter(char, info)
 				if err != nil {
 					return err
@@ -334,24 +619,48 @@ func handlePrevEnvironOpen(char rune, info *latexTransformationInfo) error {
 	braceCheck(info, char)
 	if char == '}' {
 		environ := info.getTokenInfo()
+		if info.isVerbatimEnviron(environ) || info.isCommentEnviron(environ) || info.isMarkdownTable(environ) {
+			info.verbatim = verbatimState{environ: environ, discard: info.isCommentEnviron(environ), table: info.isMarkdownTable(environ), start: info.tokenPos}
+			info.setPrevToken(token{verbatimBody, ""})
+			info.body.Reset()
+			return nil
+		}
+		info.checkMathEnviron(environ)
+		info.lintEnviron(environ)
 		info.addEnvironment(environ)
+		info.openNumberedEnviron(environ)
+		if info.isMarkdownList(environ) {
+			info.log("Replaced environment " + environ + " with Markdown list")
+			info.setPrevToken(token{none, ""})
+			return nil
+		}
 		repl, ok := info.getEnvRepl(environ)
+		if ok && environ == "enumerate" && info.profile.Lists == ListHTML {
+			repl.LeftRepl = "<ol" + formatAttribute("type", enumerateType(info.enumerateDepth())) + ">"
+		}
 		if ok {
-			info.log("Replaced environment " + environ + " with " + string(repl.leftRepl) + "..." + string(repl.rightRepl))
-			if repl.escapeRepl {
-				info.addToOutputString(repl.leftRepl)
+			info.log("Replaced environment " + environ + " with " + string(repl.LeftRepl) + "..." + string(repl.RightRepl))
+			if repl.EscapeRepl {
+				info.addToOutputString(repl.LeftRepl)
 			} else {
-				info.addRawToOutputString(repl.leftRepl)
+				info.addRawToOutputString(repl.LeftRepl)
 			}
 		}
 		info.setPrevToken(token{none, ""})
+		if ok && repl.OptArg {
+			info.setPrevToken(token{envOptArg, ""})
+		}
 		contains := info.getKnownMathEnvirons(environ)
 		if contains && info.countMathEnvs() <= 1 && info.mode == notOpen {
+			info.setEnv(mathEnv)
 			if !ok {
-				info.log("Wrapped environment " + string(environ) + " in \\( \\)")
-				info.addToOutputString("\\(\\begin{" + environ + "}")
+				open, close := info.environDelimiters()
+				if open != "" {
+					info.log("Wrapped environment " + string(environ) + " in " + open + " " + close)
+				}
+				info.addToOutputString(open + "\\begin{" + environ + "}")
 			}
-			info.setEnv(mathEnv)
+			info.startEquation(environ)
 		} else {
 			if !ok {
 				info.addToOutputString("\\begin{" + environ + "}")
@@ -371,21 +680,38 @@ func handlePrevEnvironClose(char rune, info *latexTransformationInfo) error {
 		if err != nil {
 			return err
 		}
+		if environ == "document" && info.document {
+			// the rest of the input is not read
+			info.documentEnded = true
+			info.setPrevToken(token{none, ""})
+			return nil
+		}
+		info.closeNumberedEnviron(environ)
+		if environ == info.equation.environ {
+			// the last row is tagged before \end
+			info.finishEquation()
+		}
+		if info.isMarkdownList(environ) {
+			info.setPrevToken(token{none, ""})
+			return nil
+		}
 		repl, ok := info.getEnvRepl(environ)
 		if ok {
-			if repl.escapeRepl {
-				info.addToOutputString(repl.rightRepl)
+			if repl.EscapeRepl {
+				info.addToOutputString(repl.RightRepl)
 			} else {
-				info.addRawToOutputString(repl.rightRepl)
+				info.addRawToOutputString(repl.RightRepl)
 			}
 		}
 		info.setPrevToken(token{none, ""})
 		contains := info.getKnownMathEnvirons(environ)
 		if contains && info.countMathEnvs() == 0 && info.mode == notOpen {
 			if !ok {
-				info.addToOutputString("\\end{" + environ + "}\\)")
+				_, close := info.environDelimiters()
+				info.addToOutputString("\\end{" + environ + "}" + close)
 			}
 			info.setEnv(noMathEnv)
+			info.finishEquation()
 		} else {
 			if !ok {
 				info.addToOutputString("\\end{" + environ + "}")
@@ -395,4 +721,122 @@ func handlePrevEnvironClose(char rune, info *latexTransformationInfo) error {
 		info.addTokenInfo(string(char))
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+func handlePrevRawArgs(char rune, info *latexTransformationInfo) error {
+	state := &info.rawArgs
+	raw, _ := info.getRawArgCommand(state.command)
+	if state.inOpt {
+		if char == ']' {
+			state.inOpt = false
+			state.optDone = true
+		} else {
+			state.opt += string(char)
+		}
+		return nil
+	}
+	if state.depth == 0 {
+		if char == '[' && raw.optArg && !state.optDone {
+			state.inOpt = true
+		} else if char == '{' {
+			state.depth = 1
+		} else {
+			return errors.New("expected { for command " + state.command)
+		}
+		return nil
+	}
+	if char == '{' {
+		state.depth += 1
+	} else if char == '}' {
+		state.depth -= 1
+		if state.depth == 0 {
+			info.setPrevToken(token{none, ""})
+			return raw.handler(info, state.opt, state.arg)
+		}
+	}
+	state.arg += string(char)
+	return nil
+}
+
+func handlePrevEnvOptArg(char rune, info *latexTransformationInfo) error {
+	environ, _ := info.getCurrEnv()
+	repl, _ := info.getEnvRepl(environ)
+	arg := info.getTokenInfo()
+	if arg == "" {
+		if repl.Heading != "" {
+			info.setPrevToken(token{none, ""})
+			return handleTheoremHeading(char, info, repl)
+		}
+		if char == '[' {
+			info.addTokenInfo(string(char))
+			return nil
+		}
+		info.setPrevToken(token{none, ""})
+		return handleCharacter(char, info)
+	}
+	if char == ']' {
+		info.log("Removed optional argument of environment " + environ)
+		info.setPrevToken(token{none, ""})
+	} else {
+		info.addTokenInfo(string(char))
+	}
+	return nil
+}
+
+func handleTheoremHeading(char rune, info *latexTransformationInfo, repl EnvReplacement) error {
+	if char == '[' {
+		// the title is read first, a ] in braces or math does not end it
+		info.title = groupTracker{}
+		info.body.Reset()
+		info.setPrevToken(token{theoremTitle, ""})
+		return nil
+	}
+	info.addRawToOutputString(info.theoremHeading(repl) + ".</strong> ")
+	return handleCharacter(char, info)
+}
+
+func handlePrevTheoremTitle(char rune, info *latexTransformationInfo) error {
+	if !info.title.topLevel(char) || char != ']' {
+		info.body.WriteRune(char)
+		return nil
+	}
+	title := info.body.String()
+	info.setPrevToken(token{none, ""})
+	environ, _ := info.getCurrEnv()
+	repl, _ := info.getEnvRepl(environ)
+	// the title is transformed like any other text
+	info.addRawToOutputString(info.theoremHeading(repl) + " (")
+	if err := info.transformFragment(title); err != nil {
+		return err
+	}
+	info.addRawToOutputString(").</strong> ")
+	return nil
+}
+
+// theoremHeading starts the heading of a theorem-like environment with its name and number.
+func (l *latexTransformationInfo) theoremHeading(repl EnvReplacement) string {
+	heading := "<strong>" + l.theoremNames[repl.Heading]
+	if repl.Numbered && l.options.NumberTheorems {
+		l.currentLabel = strconv.Itoa(l.nextTheoremNumber())
+		heading += " " + l.currentLabel
+	}
+	return heading
+}
+
+// transformFragment transforms s at the current position of the output, without trailing spaces.
+func (l *latexTransformationInfo) transformFragment(s string) error {
+	start := l.output.Len()
+	// the space ends a command at the end of s
+	for _, char := range s + " " {
+		err := handleCharacter(char, l)
+		if err == nil {
+			err = l.err
+		}
+		if err != nil {
+			return err
+		}
+	}
+	trimmed := bytes.TrimRight(l.output.Bytes()[start:], " ")
+	l.output.Truncate(start + len(trimmed))
+	return nil
+}
@@ -0,0 +1,138 @@
+package latex
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	mathjaxCommandRule = "mathjax-unknown-command"
+	mathjaxEnvironRule = "mathjax-unknown-environment"
+	dollarDisplayRule  = "dollar-display"
+	overRule           = "over"
+	eqnarrayRule       = "eqnarray"
+	textNewlineRule    = "text-newline"
+	emptyItemRule      = "empty-item"
+	unknownEnvironRule = "unknown-environment"
+	mathInTextRule     = "math-command-in-text"
+	unclosedRule       = "unclosed"
+	transformErrorRule = "error"
+)
+
+// GetLintRules returns every rule reported by Lint with its default severity.
+func GetLintRules() map[string]Severity {
+	return map[string]Severity{
+		mathjaxCommandRule:     SeverityWarning,
+		mathjaxEnvironRule:     SeverityWarning,
+		dollarDisplayRule:      SeverityWarning,
+		overRule:               SeverityWarning,
+		eqnarrayRule:           SeverityWarning,
+		textNewlineRule:        SeverityInfo,
+		emptyItemRule:          SeverityWarning,
+		unknownEnvironRule:     SeverityWarning,
+		mathInTextRule:         SeverityError,
+		transformErrorRule:     SeverityError,
+		duplicateLabelRule:     SeverityWarning,
+		undefinedReferenceRule: SeverityWarning,
+		invalidUTF8Rule:        SeverityError,
+		unclosedRule:           SeverityError,
+		reservedCharacterRule:  SeverityError,
+	}
+}
+
+// GetTransformRules returns the rules reported by TransformLatex.
+func GetTransformRules() map[string]Severity {
+	return map[string]Severity{
+		mathjaxCommandRule:     SeverityWarning,
+		mathjaxEnvironRule:     SeverityWarning,
+		duplicateLabelRule:     SeverityWarning,
+		undefinedReferenceRule: SeverityWarning,
+		htmlUnbalancedRule:     SeverityWarning,
+		htmlNotAllowedRule:     SeverityWarning,
+		invalidUTF8Rule:        SeverityWarning,
+		unclosedRule:           SeverityWarning,
+		reservedCharacterRule:  SeverityWarning,
+	}
+}
+
+// LintOptions configures Lint by rule id.
+type LintOptions struct {
+	// Disable lists rules that are not reported.
+	Disable []string
+	// Severity overrides the default severity of single rules.
+	Severity map[string]Severity
+	// Options are the transformation options the input is checked with.
+	Options Options
+}
+
+// Lint checks the input for style and portability problems without producing output. Errors
+// that would make TransformLatex fail are reported with the rule "error".
+func Lint(latex string, lintOptions LintOptions) []Diagnostic {
+	info := NewTransformer(DefaultRules(), lintOptions.Options).newTransformationInfo()
+	info.rules = GetLintRules()
+	for rule, severity := range lintOptions.Severity {
+		info.rules[rule] = severity
+	}
+	for _, rule := range lintOptions.Disable {
+		delete(info.rules, rule)
+	}
+	err := runTransformation(strings.NewReader(latex), info)
+	if err != nil {
+		info.diagnose(transformErrorRule, info.pos, err.Error())
+	} else if info.pendingItem != nil {
+		info.diagnose(emptyItemRule, *info.pendingItem, "empty \\item")
+	}
+	info.resolveReferences(info.output.String())
+	sort.SliceStable(info.diagnostics, func(i, j int) bool {
+		a, b := info.diagnostics[i].Position, info.diagnostics[j].Position
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return info.diagnostics
+}
+
+func (l *latexTransformationInfo) lintCommand(command string) {
+	if command == "item" || command == "end" {
+		if l.pendingItem != nil {
+			l.diagnose(emptyItemRule, *l.pendingItem, "empty \\item")
+		}
+		l.pendingItem = nil
+		if command == "item" {
+			pos := l.tokenPos
+			l.pendingItem = &pos
+		}
+	} else {
+		l.pendingItem = nil
+	}
+	if command == "over" && l.isMathContext() {
+		l.diagnose(overRule, l.tokenPos, "\\over is deprecated, use \\frac{...}{...}")
+	}
+	if l.isTextMode() && l.mathOnlyCommands[command] {
+		if _, symbol := l.getTextSymbol(command); !symbol {
+			l.diagnose(mathInTextRule, l.tokenPos, "math command \\"+command+" used outside of math mode")
+		}
+	}
+}
+
+func (l *latexTransformationInfo) lintText(char rune) {
+	if !unicode.IsSpace(char) {
+		l.pendingItem = nil
+	}
+}
+
+func (l *latexTransformationInfo) lintEnviron(environ string) {
+	if environ == "eqnarray" || environ == "eqnarray*" {
+		l.diagnose(eqnarrayRule, l.tokenPos, "eqnarray is deprecated, use align")
+		return
+	}
+	if l.isMathContext() {
+		return
+	}
+	_, repl := l.getEnvRepl(environ)
+	if !repl && !l.getKnownMathEnvirons(environ) {
+		l.diagnose(unknownEnvironRule, l.tokenPos, "environment "+environ+" is not transformed and will appear verbatim")
+	}
+}
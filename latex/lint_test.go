@@ -0,0 +1,81 @@
+package latex
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLintRules(t *testing.T) {
+	tests := []struct {
+		latex string
+		want  []Diagnostic
+	}{
+		{"$a \\foo b$", []Diagnostic{
+			{mathjaxCommandRule, SeverityWarning, Position{1, 4, ""}, "math command \\foo is not supported by MathJax"},
+		}},
+		{"\\begin{eqnarray}a\\end{eqnarray}", []Diagnostic{
+			{eqnarrayRule, SeverityWarning, Position{1, 1, ""}, "eqnarray is deprecated, use align"},
+		}},
+		{"$$x$$", []Diagnostic{
+			{dollarDisplayRule, SeverityWarning, Position{1, 1, ""}, "$$ display math, use \\[...\\]"},
+		}},
+		{"${a \\over b}$", []Diagnostic{
+			{overRule, SeverityWarning, Position{1, 5, ""}, "\\over is deprecated, use \\frac{...}{...}"},
+		}},
+		{"a\\\\b", []Diagnostic{
+			{textNewlineRule, SeverityInfo, Position{1, 2, ""}, "\\\\ outside of math, use an empty line or an HTML element"},
+		}},
+		{"\\begin{itemize}\\item\\item b\\end{itemize}", []Diagnostic{
+			{emptyItemRule, SeverityWarning, Position{1, 16, ""}, "empty \\item"},
+		}},
+		{"\\begin{foo}x\\end{foo}", []Diagnostic{
+			{unknownEnvironRule, SeverityWarning, Position{1, 1, ""}, "environment foo is not transformed and will appear verbatim"},
+		}},
+		{"\\alpha", []Diagnostic{
+			{mathInTextRule, SeverityError, Position{1, 1, ""}, "math command \\alpha used outside of math mode"},
+		}},
+		{"\\label{a}\\label{a}", []Diagnostic{
+			{duplicateLabelRule, SeverityWarning, Position{1, 10, ""}, "label a is defined more than once"},
+		}},
+		{"\\ref{b}", []Diagnostic{
+			{undefinedReferenceRule, SeverityWarning, Position{1, 1, ""}, "reference to undefined label b"},
+		}},
+		{"$a$ and \\[b\\]", []Diagnostic{}},
+	}
+	for _, test := range tests {
+		got := Lint(test.latex, LintOptions{})
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Lint(%q) = %v, want %v", test.latex, got, test.want)
+		}
+	}
+}
+
+func TestLintDisable(t *testing.T) {
+	latex := "$$x \\over y$$"
+	got := Lint(latex, LintOptions{Disable: []string{dollarDisplayRule}})
+	want := []Diagnostic{
+		{overRule, SeverityWarning, Position{1, 5, ""}, "\\over is deprecated, use \\frac{...}{...}"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint(%q) = %v, want %v", latex, got, want)
+	}
+	got = Lint(latex, LintOptions{Disable: []string{dollarDisplayRule, overRule}})
+	if len(got) != 0 {
+		t.Errorf("Lint(%q) with every rule disabled = %v, want no diagnostics", latex, got)
+	}
+}
+
+func TestLintSeverity(t *testing.T) {
+	latex := "a\\\\b \\alpha"
+	got := Lint(latex, LintOptions{Severity: map[string]Severity{
+		textNewlineRule: SeverityError,
+		mathInTextRule:  SeverityWarning,
+	}})
+	want := []Diagnostic{
+		{textNewlineRule, SeverityError, Position{1, 2, ""}, "\\\\ outside of math, use an empty line or an HTML element"},
+		{mathInTextRule, SeverityWarning, Position{1, 6, ""}, "math command \\alpha used outside of math mode"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint(%q) = %v, want %v", latex, got, want)
+	}
+}
@@ -0,0 +1,253 @@
+package latex
+
+import (
+	"bytes"
+	"strings"
+)
+
+func GetMarkdownCommandReplacements() map[string]CommandReplacement {
+	return map[string]CommandReplacement{
+		"textbf":         {false, true, false, "**", "**"},
+		"textit":         {false, true, false, "*", "*"},
+		"emph":           {false, true, false, "*", "*"},
+		"section":        {false, true, false, "# ", ""},
+		"section*":       {false, true, false, "# ", ""},
+		"subsection":     {false, true, false, "## ", ""},
+		"subsection*":    {false, true, false, "## ", ""},
+		"subsubsection":  {false, true, false, "### ", ""},
+		"subsubsection*": {false, true, false, "### ", ""},
+	}
+}
+
+// markdownListMarkers maps the list environments to the marker of their items.
+var markdownListMarkers = map[string]string{"itemize": "- ", "enumerate": "1. "}
+
+// markdownSpecial lists the characters that are escaped in Markdown text. LaTeX escapes such as
+// \_ or \# are already Markdown escapes and are kept.
+const markdownSpecial = "*_`#[]|<>"
+
+var markdownEscaper = func() *strings.Replacer {
+	pairs := make([]string, 0)
+	for _, char := range markdownSpecial {
+		pairs = append(pairs, "\\"+string(char), "\\"+string(char))
+	}
+	for _, char := range markdownSpecial {
+		pairs = append(pairs, string(char), "\\"+string(char))
+	}
+	return strings.NewReplacer(pairs...)
+}()
+
+// escapeMarkdownBlockMarker escapes text that would start a list at the beginning of a line,
+// such as - or + or the dot of 1.
+func (l *latexTransformationInfo) escapeMarkdownBlockMarker(text string) string {
+	if text == "" || !strings.ContainsRune("-+.)", rune(text[0])) {
+		return text
+	}
+	output := l.output.Bytes()
+	line := output[bytes.LastIndexByte(output, '\n')+1:]
+	if index := bytes.LastIndex(line, []byte(newlinePlaceholder)); index >= 0 {
+		line = line[index+len(newlinePlaceholder):]
+	}
+	trimmed := strings.TrimLeft(string(line), " ")
+	digits := trimmed != "" && strings.Trim(trimmed, "0123456789") == ""
+	if (trimmed == "" && (text[0] == '-' || text[0] == '+')) || (digits && (text[0] == '.' || text[0] == ')')) {
+		return "\\" + text
+	}
+	return text
+}
+
+func (l *latexTransformationInfo) isMarkdown() bool {
+	return l.profile.Format == FormatMarkdown
+}
+
+// handleMarkdownLineBreak writes \\ in text as a Markdown hard line break. If the output may
+// contain html, the newline after it is written as <br> like every newline of html output.
+func handleMarkdownLineBreak(info *latexTransformationInfo) {
+	if info.options.HTML == HTMLNever {
+		info.log("Replaced newline \\\\ with a Markdown line break")
+		info.addRawToOutputString("  ")
+	} else {
+		info.log("Replaced newline \\\\ with <br>")
+		info.useHtml()
+	}
+	info.hardBreak = true
+}
+
+// startLineAfterBreak starts a new line after a Markdown line break unless the input does. Spaces
+// before the next line are dropped, since they would indent it.
+func startLineAfterBreak(char rune, info *latexTransformationInfo) bool {
+	if char == ' ' {
+		return false
+	}
+	info.hardBreak = false
+	if char != '\n' {
+		info.output.WriteString(newlinePlaceholder)
+	}
+	return true
+}
+
+func (l *latexTransformationInfo) isMarkdownList(environ string) bool {
+	_, list := markdownListMarkers[environ]
+	return l.isMarkdown() && list
+}
+
+func (l *latexTransformationInfo) isMarkdownTable(environ string) bool {
+	return l.isMarkdown() && environ == "tabular"
+}
+
+// handleMarkdownItem starts an item on a new line, indented to the content of the enclosing items.
+func handleMarkdownItem(char rune, info *latexTransformationInfo) error {
+	environ, _ := info.getCurrEnv()
+	indent := ""
+	for _, outer := range info.environmentStack[:len(info.environmentStack)-1] {
+		if marker, list := markdownListMarkers[outer]; list {
+			indent += strings.Repeat(" ", len(marker))
+		}
+	}
+	// blank lines between items would make the list loose
+	trimmed := bytes.TrimRight(info.output.Bytes(), " \t\n"+newlinePlaceholder)
+	info.output.Truncate(len(trimmed))
+	if info.output.Len() > 0 {
+		info.addRawToOutputString(newlinePlaceholder)
+	}
+	info.log("Replaced \\item with Markdown list item")
+	info.addRawToOutputString(indent + markdownListMarkers[environ])
+	info.setPrevToken(token{textSymbolEnd, ""})
+	return handleCharacter(char, info)
+}
+
+// markdownTable turns the body of a tabular environment into a pipe table. The first row is the
+// header, cells are transformed like the surrounding text.
+func (l *latexTransformationInfo) markdownTable(body string) (string, error) {
+	if _, rest, ok := cutDelimited(body, '[', ']'); ok {
+		body = rest
+	}
+	spec, body := cutGroup(body)
+	rows := make([][]string, 0)
+	columns := 0
+	for _, line := range strings.Split(body, "\\\\") {
+		for _, rule := range []string{"\\hline", "\\toprule", "\\midrule", "\\bottomrule"} {
+			line = strings.ReplaceAll(line, rule, "")
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		cells := splitCells(line)
+		for i, cell := range cells {
+			transformed, err := l.transformCell(cell)
+			if err != nil {
+				return "", err
+			}
+			cells[i] = transformed
+		}
+		columns = max(columns, len(cells))
+		rows = append(rows, cells)
+	}
+	alignments := columnAlignments(spec)
+	columns = max(columns, len(alignments))
+	if len(rows) == 0 {
+		rows = append(rows, []string{})
+	}
+	var table strings.Builder
+	writeRow := func(cells []string) {
+		table.WriteString("|")
+		for i := 0; i < columns; i++ {
+			cell := ""
+			if i < len(cells) {
+				cell = cells[i]
+			}
+			table.WriteString(" " + cell + " |")
+		}
+		table.WriteString("\n")
+	}
+	writeRow(rows[0])
+	separator := make([]string, columns)
+	for i := range separator {
+		separator[i] = "---"
+		if i < len(alignments) {
+			separator[i] = alignments[i]
+		}
+	}
+	writeRow(separator)
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return strings.TrimSuffix(table.String(), "\n"), nil
+}
+
+// transformCell transforms a table cell and returns it on one line with the | escaped.
+func (l *latexTransformationInfo) transformCell(cell string) (string, error) {
+	start := l.output.Len()
+	if err := l.transformFragment(cell); err != nil {
+		return "", err
+	}
+	output := strings.NewReplacer("\n", " ", newlinePlaceholder, " ").Replace(string(l.output.Bytes()[start:]))
+	l.output.Truncate(start)
+	output = strings.TrimSpace(output)
+	var escaped strings.Builder
+	for i, char := range output {
+		if char == '|' && (i == 0 || output[i-1] != '\\') {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteRune(char)
+	}
+	return escaped.String(), nil
+}
+
+// splitCells splits a table row at the & that are not escaped, in math or in a group.
+func splitCells(row string) []string {
+	cells := make([]string, 0)
+	start := 0
+	var tracker groupTracker
+	for i, char := range row {
+		if tracker.topLevel(char) && char == '&' {
+			cells = append(cells, row[start:i])
+			start = i + 1
+		}
+	}
+	return append(cells, row[start:])
+}
+
+// columnAlignments reads the alignment of each column from a tabular column specification.
+func columnAlignments(spec string) []string {
+	alignments := make([]string, 0)
+	for len(spec) > 0 {
+		char := spec[0]
+		spec = spec[1:]
+		switch char {
+		case 'l':
+			alignments = append(alignments, ":---")
+		case 'c':
+			alignments = append(alignments, ":---:")
+		case 'r':
+			alignments = append(alignments, "---:")
+		case 'p', 'm', 'b', 'X':
+			alignments = append(alignments, "---")
+		case '{':
+			// arguments of p{..}, @{..} and >{..}
+			_, spec = cutGroup("{" + spec)
+		}
+	}
+	return alignments
+}
+
+// cutGroup splits a leading {...} group with nested braces off s, returning its content and the rest.
+func cutGroup(s string) (string, string) {
+	s = strings.TrimLeft(s, " \n")
+	if !strings.HasPrefix(s, "{") {
+		return "", s
+	}
+	depth := 0
+	for i, char := range s {
+		if char == '{' {
+			depth += 1
+		} else if char == '}' {
+			depth -= 1
+			if depth == 0 {
+				return s[1:i], s[i+1:]
+			}
+		}
+	}
+	return "", s
+}
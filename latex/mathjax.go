@@ -0,0 +1,144 @@
+package latex
+
+import "strings"
+
+// Commands and environments of the MathJax TeX input (base, AMS and the mhchem, cancel, color
+// and enclose extensions loaded by STACK), grouped by whether they are also valid outside math.
+
+const mathjaxTextCommands = `
+text textbf textit textrm textsf texttt textup textnormal mbox hbox vbox fbox emph underline
+quad qquad enspace thinspace negthinspace space hspace vspace kern hskip phantom hphantom vphantom smash
+color textcolor colorbox fcolorbox href style class cssId label ref eqref tag notag nonumber
+newcommand renewcommand newenvironment def let begin end require unicode rlap llap
+`
+
+const mathjaxGreek = `
+alpha beta gamma delta epsilon varepsilon zeta eta theta vartheta iota kappa varkappa lambda mu nu xi
+pi varpi rho varrho sigma varsigma tau upsilon phi varphi chi psi omega digamma
+Gamma Delta Theta Lambda Xi Pi Sigma Upsilon Phi Psi Omega
+varGamma varDelta varTheta varLambda varXi varPi varSigma varUpsilon varPhi varPsi varOmega
+`
+
+const mathjaxBinaryOperators = `
+pm mp times div cdot ast star circ bullet oplus ominus otimes oslash odot cap cup sqcap sqcup vee wedge
+land lor wr amalg setminus smallsetminus uplus bigcirc bigtriangleup bigtriangledown triangleleft triangleright
+lhd rhd unlhd unrhd dagger ddagger ltimes rtimes leftthreetimes rightthreetimes curlywedge curlyvee barwedge
+veebar doublebarwedge boxplus boxminus boxtimes boxdot circledast circledcirc circleddash centerdot dotplus
+divideontimes intercal Cap Cup doublecap doublecup
+`
+
+const mathjaxRelations = `
+leq le geq ge neq ne equiv sim simeq approx cong propto prec succ preceq succeq ll gg subset supset subseteq
+supseteq sqsubset sqsupset sqsubseteq sqsupseteq in ni notin owns mid parallel perp models vdash dashv vDash
+Vdash Vvdash bowtie smile frown asymp doteq doteqdot leqq geqq leqslant geqslant lessapprox gtrapprox lesssim
+gtrsim lessgtr gtrless lesseqgtr gtreqless lesseqqgtr gtreqqless eqslantless eqslantgtr nleq ngeq nless ngtr
+nsubseteq nsupseteq subsetneq supsetneq subsetneqq supsetneqq varsubsetneq varsupsetneq nmid nparallel ncong
+nsim approxeq thicksim thickapprox backsim backsimeq triangleq eqcirc circeq bumpeq Bumpeq risingdotseq
+fallingdotseq therefore because varpropto between pitchfork shortmid shortparallel nshortmid nshortparallel
+trianglelefteq trianglerighteq ntriangleleft ntriangleright ntrianglelefteq ntrianglerighteq vartriangleleft
+vartriangleright vartriangle lll llless ggg gggtr Subset Supset subseteqq supseteqq nsubseteqq nsupseteqq precsim
+succsim precapprox succapprox preccurlyeq succcurlyeq curlyeqprec curlyeqsucc nprec nsucc npreceq nsucceq
+precneqq succneqq precnsim succnsim precnapprox succnapprox lneq gneq lneqq gneqq lvertneqq gvertneqq lnsim
+gnsim lnapprox gnapprox nleqq ngeqq nleqslant ngeqslant nvdash nvDash nVdash nVDash eqsim iff implies
+impliedby lt gt
+`
+
+const mathjaxArrows = `
+leftarrow rightarrow to gets leftrightarrow Leftarrow Rightarrow Leftrightarrow longleftarrow longrightarrow
+longleftrightarrow Longleftarrow Longrightarrow Longleftrightarrow mapsto longmapsto hookleftarrow hookrightarrow
+uparrow downarrow updownarrow Uparrow Downarrow Updownarrow nearrow searrow swarrow nwarrow leftharpoonup
+leftharpoondown rightharpoonup rightharpoondown rightleftharpoons leftrightharpoons leadsto nleftarrow
+nrightarrow nLeftarrow nRightarrow nleftrightarrow nLeftrightarrow leftleftarrows rightrightarrows
+leftrightarrows rightleftarrows upuparrows downdownarrows Lsh Rsh looparrowleft looparrowright curvearrowleft
+curvearrowright circlearrowleft circlearrowright twoheadleftarrow twoheadrightarrow leftarrowtail
+rightarrowtail rightsquigarrow leftrightsquigarrow multimap upharpoonleft upharpoonright downharpoonleft
+downharpoonright restriction Lleftarrow Rrightarrow dashleftarrow dashrightarrow xleftarrow xrightarrow
+`
+
+const mathjaxSymbols = `
+infty nabla partial forall exists nexists emptyset varnothing neg lnot top bot angle measuredangle
+sphericalangle triangle triangledown square Box blacksquare lozenge blacklozenge bigstar blacktriangle
+blacktriangledown blacktriangleleft blacktriangleright diamond Diamond diamondsuit heartsuit clubsuit spadesuit
+flat natural sharp prime backprime aleph beth gimel daleth hbar hslash imath jmath ell wp Re Im mho complement
+eth Finv Game surd checkmark maltese circledR circledS yen S P dots ldots cdots vdots ddots dotsb dotsc dotsi
+dotsm dotso colon ldotp cdotp vert Vert backslash lbrace rbrace langle rangle lceil rceil lfloor rfloor lvert
+rvert lVert rVert ulcorner urcorner llcorner lrcorner lgroup rgroup lmoustache rmoustache
+`
+
+const mathjaxOperators = `
+sum prod coprod int iint iiint iiiint oint intop smallint bigcap bigcup bigsqcup bigvee bigwedge bigodot
+bigotimes bigoplus biguplus arccos arcsin arctan arg cos cosh cot coth csc deg det dim exp gcd hom inf ker lg
+lim liminf limsup ln log max min Pr sec sin sinh sup tan tanh injlim projlim varliminf varlimsup varinjlim
+varprojlim operatorname
+`
+
+const mathjaxConstructs = `
+frac dfrac tfrac cfrac genfrac sqrt root of binom dbinom tbinom choose brack brace atop over above
+overline overbrace underbrace overrightarrow overleftarrow overleftrightarrow underrightarrow underleftarrow
+underleftrightarrow widehat widetilde hat tilde bar vec dot ddot dddot ddddot acute grave breve check mathring
+stackrel overset underset buildrel left right middle big Big bigg Bigg bigl bigr Bigl Bigr biggl biggr Biggl
+Biggr bigm Bigm biggm Biggm limits nolimits displaystyle textstyle scriptstyle scriptscriptstyle mathrm mathit
+mathbf mathbb mathcal mathfrak mathscr mathsf mathtt mathnormal boldsymbol pmb rm it bf sf tt cal frak Bbb
+bold scr mathop mathbin mathrel mathopen mathclose mathpunct mathord mathinner substack sideset pmod bmod mod pod
+not cancel bcancel xcancel cancelto enclose boxed mathstrut strut cr hline hdashline displaylines
+mspace mkern mskip medspace thickspace negmedspace negthickspace ce pu
+`
+
+const mathjaxEnvirons = `
+matrix pmatrix bmatrix Bmatrix vmatrix Vmatrix smallmatrix cases array subarray align align* aligned alignat
+alignat* alignedat eqnarray eqnarray* equation equation* gather gather* gathered multline multline* split CD
+`
+
+func wordSet(lists ...string) map[string]bool {
+	set := make(map[string]bool)
+	for _, list := range lists {
+		for _, word := range strings.Fields(list) {
+			set[word] = true
+		}
+	}
+	return set
+}
+
+func GetMathJaxCommands() map[string]bool {
+	return wordSet(mathjaxTextCommands, mathjaxGreek, mathjaxBinaryOperators, mathjaxRelations, mathjaxArrows,
+		mathjaxSymbols, mathjaxOperators, mathjaxConstructs)
+}
+
+// GetMathOnlyCommands returns the MathJax commands that have no meaning outside of math.
+func GetMathOnlyCommands() map[string]bool {
+	commands := wordSet(mathjaxGreek, mathjaxBinaryOperators, mathjaxRelations, mathjaxArrows, mathjaxSymbols,
+		mathjaxOperators, mathjaxConstructs)
+	for _, textCommand := range strings.Fields("it bf rm sf tt strut hline cr") {
+		delete(commands, textCommand)
+	}
+	return commands
+}
+
+func GetMathJaxEnvirons() map[string]bool {
+	return wordSet(mathjaxEnvirons)
+}
+
+func (l *latexTransformationInfo) isMathContext() bool {
+	return l.isMathModeActive() || l.getEnv() == mathEnv
+}
+
+// checkMathCommand warns about control words in math that MathJax cannot render.
+func (l *latexTransformationInfo) checkMathCommand(command string) {
+	if !l.isMathContext() || l.mathjaxCommands[command] {
+		return
+	}
+	if _, custom := l.customCommands()[command]; custom {
+		return
+	}
+	if _, repl := l.getCommandReplacement(command); repl {
+		return
+	}
+	l.diagnose(mathjaxCommandRule, l.tokenPos, "math command \\"+command+" is not supported by MathJax")
+}
+
+func (l *latexTransformationInfo) checkMathEnviron(environ string) {
+	if !l.isMathContext() || l.mathjaxEnvirons[environ] {
+		return
+	}
+	l.diagnose(mathjaxEnvironRule, l.tokenPos, "math environment "+environ+" is not supported by MathJax")
+}
@@ -0,0 +1,57 @@
+package latex
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// GetMathSymbols maps Unicode characters pasted from PDFs or word processors to the commands
+// that replace them in math mode.
+func GetMathSymbols() map[rune]string {
+	return map[rune]string{
+		// Greek letters, the variant forms follow the usual glyphs
+		'α': "\\alpha", 'β': "\\beta", 'γ': "\\gamma", 'δ': "\\delta", 'ε': "\\varepsilon", 'ϵ': "\\epsilon",
+		'ζ': "\\zeta", 'η': "\\eta", 'θ': "\\theta", 'ϑ': "\\vartheta", 'ι': "\\iota", 'κ': "\\kappa",
+		'λ': "\\lambda", 'μ': "\\mu", 'ν': "\\nu", 'ξ': "\\xi", 'ο': "o", 'π': "\\pi", 'ϖ': "\\varpi",
+		'ρ': "\\rho", 'ϱ': "\\varrho", 'σ': "\\sigma", 'ς': "\\varsigma", 'τ': "\\tau", 'υ': "\\upsilon",
+		'φ': "\\varphi", 'ϕ': "\\phi", 'χ': "\\chi", 'ψ': "\\psi", 'ω': "\\omega",
+		'Γ': "\\Gamma", 'Δ': "\\Delta", 'Θ': "\\Theta", 'Λ': "\\Lambda", 'Ξ': "\\Xi", 'Π': "\\Pi",
+		'Σ': "\\Sigma", 'Υ': "\\Upsilon", 'Φ': "\\Phi", 'Ψ': "\\Psi", 'Ω': "\\Omega",
+		// relations
+		'≤': "\\leq", '≥': "\\geq", '≠': "\\neq", '≈': "\\approx", '≡': "\\equiv", '∼': "\\sim",
+		'≃': "\\simeq", '≅': "\\cong", '∝': "\\propto", '≪': "\\ll", '≫': "\\gg", '⊂': "\\subset",
+		'⊃': "\\supset", '⊆': "\\subseteq", '⊇': "\\supseteq", '∈': "\\in", '∉': "\\notin", '∋': "\\ni",
+		'⊥': "\\perp", '∥': "\\parallel", '∣': "\\mid", '≔': ":=",
+		// arrows
+		'→': "\\to", '←': "\\leftarrow", '↔': "\\leftrightarrow", '⇒': "\\Rightarrow", '⇐': "\\Leftarrow",
+		'⇔': "\\Leftrightarrow", '↦': "\\mapsto", '↑': "\\uparrow", '↓': "\\downarrow",
+		'⟶': "\\longrightarrow", '⟹': "\\Longrightarrow", '⟺': "\\Longleftrightarrow",
+		// operators
+		'∑': "\\sum", '∏': "\\prod", '∫': "\\int", '∬': "\\iint", '∮': "\\oint", '√': "\\sqrt",
+		'∂': "\\partial", '∇': "\\nabla", '±': "\\pm", '∓': "\\mp", '×': "\\times", '÷': "\\div",
+		'·': "\\cdot", '⋅': "\\cdot", '∘': "\\circ", '∩': "\\cap", '∪': "\\cup", '∖': "\\setminus",
+		'∧': "\\wedge", '∨': "\\vee", '¬': "\\neg", '⊕': "\\oplus", '⊗': "\\otimes", '−': "-",
+		// logic, sets and other symbols
+		'∀': "\\forall", '∃': "\\exists", '∄': "\\nexists", '∅': "\\emptyset", '∞': "\\infty",
+		'ℕ': "\\mathbb{N}", 'ℤ': "\\mathbb{Z}", 'ℚ': "\\mathbb{Q}", 'ℝ': "\\mathbb{R}", 'ℂ': "\\mathbb{C}",
+		'ℓ': "\\ell", 'ℏ': "\\hbar", '∠': "\\angle", '△': "\\triangle", '…': "\\ldots", '⋯': "\\cdots",
+		'⟨': "\\langle", '⟩': "\\rangle", '⌊': "\\lfloor", '⌋': "\\rfloor", '⌈': "\\lceil", '⌉': "\\rceil",
+		'‖': "\\|", '′': "'", '″': "''", '°': "^\\circ",
+	}
+}
+
+// replaceMathSymbol writes the command for a Unicode symbol in math and reports whether there is one.
+// Symbols in the argument of a text command such as \text are kept.
+func replaceMathSymbol(char rune, info *latexTransformationInfo) bool {
+	command, ok := info.mathSymbols[char]
+	if !ok || !info.isMathContext() || info.mathText > 0 {
+		return false
+	}
+	info.log("Replaced " + string(char) + " in math with " + command)
+	info.addToOutputString(command)
+	// a following letter must not continue the control word
+	last, _ := utf8.DecodeLastRuneInString(command)
+	info.controlWordEnd = strings.HasPrefix(command, "\\") && unicode.IsLetter(last)
+	return true
+}
@@ -0,0 +1,141 @@
+package latex
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	invalidUTF8Rule       = "invalid-utf8"
+	reservedCharacterRule = "reserved-character"
+)
+
+// InputOptions configures the normalisation of the input. The zero value removes a byte order
+// mark and turns \r\n and \r into \n.
+type InputOptions struct {
+	// KeepBOM keeps a leading byte order mark.
+	KeepBOM bool `json:"keepBOM,omitempty"`
+	// KeepLineEndings keeps carriage returns.
+	KeepLineEndings bool `json:"keepLineEndings,omitempty"`
+	// TabWidth expands tabs to spaces up to the next multiple of TabWidth columns if positive.
+	// Positions of diagnostics then count the spaces.
+	TabWidth int `json:"tabWidth,omitempty"`
+	// UnicodeSpaces removes zero-width spaces and replaces non-breaking and other Unicode
+	// spaces in math by a space, since MathJax does not accept them. Outside of math only the
+	// zero-width space and the word joiner are removed, the joiners shape text in some scripts.
+	UnicodeSpaces bool `json:"unicodeSpaces,omitempty"`
+}
+
+// inputNormalizer applies InputOptions to the runes of reader while they are transformed.
+type inputNormalizer struct {
+	reader   io.RuneReader
+	info     *latexTransformationInfo
+	started  bool
+	pending  []rune // expanded spaces
+	next     rune   // read after a carriage return
+	nextSize int
+	hasNext  bool
+	column   int
+	err      error
+}
+
+func newInputNormalizer(reader io.RuneReader, info *latexTransformationInfo) *inputNormalizer {
+	return &inputNormalizer{reader: reader, info: info}
+}
+
+func (n *inputNormalizer) ReadRune() (rune, int, error) {
+	for {
+		if len(n.pending) > 0 {
+			char := n.pending[0]
+			n.pending = n.pending[1:]
+			return char, utf8.RuneLen(char), nil
+		}
+		var char rune
+		var size int
+		if n.hasNext {
+			char, size, n.hasNext = n.next, n.nextSize, false
+		} else if n.err != nil {
+			return 0, 0, n.err
+		} else {
+			var err error
+			char, size, err = n.reader.ReadRune()
+			if err != nil {
+				return char, size, err
+			}
+		}
+		if char, keep := n.normalize(char, size); keep {
+			return char, size, nil
+		}
+	}
+}
+
+func (n *inputNormalizer) normalize(char rune, size int) (rune, bool) {
+	options := n.info.options.Input
+	first := !n.started
+	n.started = true
+	if char == utf8.RuneError && size == 1 {
+		n.info.diagnose(invalidUTF8Rule, n.info.pos, "invalid UTF-8, replaced by U+FFFD")
+	}
+	if isReservedCharacter(char) {
+		n.info.diagnose(reservedCharacterRule, n.info.pos, fmt.Sprintf("character %U is reserved for the transformation, replaced by U+FFFD", char))
+		char = utf8.RuneError
+	}
+	switch {
+	case char == '\ufeff' && first && !options.KeepBOM:
+		n.info.log("Removed byte order mark")
+		return 0, false
+	case char == '\r' && !options.KeepLineEndings:
+		n.info.log("Normalised line endings")
+		next, size, err := n.reader.ReadRune()
+		if err != nil {
+			n.err = err
+		} else if next != '\n' {
+			n.next, n.nextSize, n.hasNext = next, size, true
+		}
+		n.column = 0
+		return '\n', true
+	case char == '\n':
+		n.column = 0
+		return char, true
+	case char == '\t' && options.TabWidth > 0:
+		spaces := options.TabWidth - n.column%options.TabWidth
+		n.column += spaces
+		n.info.log("Expanded tabs")
+		n.pending = append(n.pending, []rune(strings.Repeat(" ", spaces-1))...)
+		return ' ', true
+	case options.UnicodeSpaces && isZeroWidthSpace(char) && (n.info.isMathContext() || char == '\u200b' || char == '\u2060'):
+		n.info.log("Removed zero-width space")
+		return 0, false
+	case options.UnicodeSpaces && isUnicodeSpace(char) && n.info.isMathContext():
+		n.info.log("Replaced Unicode space in math with a space")
+		char = ' '
+	}
+	n.column += 1
+	return char, true
+}
+
+// isReservedCharacter reports the private use characters the transformation uses as placeholders.
+func isReservedCharacter(char rune) bool {
+	return char >= '\ue000' && char <= '\ue01f'
+}
+
+func isZeroWidthSpace(char rune) bool {
+	switch char {
+	case '\u200b', '\u200c', '\u200d', '\u2060', '\ufeff':
+		return true
+	}
+	return false
+}
+
+// isUnicodeSpace reports the spaces other than the ASCII ones, such as the non-breaking space.
+func isUnicodeSpace(char rune) bool {
+	switch {
+	case char == '\u00a0', char == '\u202f', char == '\u205f', char == '\u3000':
+		return true
+	case char >= '\u2000' && char <= '\u200a':
+		return true
+	}
+	return false
+}
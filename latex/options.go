@@ -0,0 +1,182 @@
+package latex
+
+import (
+	"errors"
+	"strconv"
+)
+
+type HTMLPolicy int
+
+const (
+	// HTMLAuto writes html when the input uses constructs such as lists, code or footnotes.
+	HTMLAuto HTMLPolicy = iota
+	// HTMLAlways escapes text for html even if no html is needed.
+	HTMLAlways
+	// HTMLNever never escapes text. Constructs that need html still write their tags.
+	HTMLNever
+)
+
+var htmlPolicyNames = []string{"auto", "always", "never"}
+
+func (p HTMLPolicy) MarshalText() ([]byte, error) {
+	return marshalName(htmlPolicyNames, int(p), "html policy")
+}
+
+func (p *HTMLPolicy) UnmarshalText(text []byte) error {
+	value, err := unmarshalName(htmlPolicyNames, string(text), "html policy")
+	*p = HTMLPolicy(value)
+	return err
+}
+
+type CommentPolicy int
+
+const (
+	// CommentsRemove drops comments including their line break.
+	CommentsRemove CommentPolicy = iota
+	// CommentsKeep writes comments as they are.
+	CommentsKeep
+	// CommentsHTML turns comments in text into html comments; comments in math are kept.
+	CommentsHTML
+)
+
+var commentPolicyNames = []string{"remove", "keep", "html"}
+
+func (p CommentPolicy) MarshalText() ([]byte, error) {
+	return marshalName(commentPolicyNames, int(p), "comment policy")
+}
+
+func (p *CommentPolicy) UnmarshalText(text []byte) error {
+	value, err := unmarshalName(commentPolicyNames, string(text), "comment policy")
+	*p = CommentPolicy(value)
+	return err
+}
+
+// DocumentMode selects whether the input is treated as a full document with a preamble.
+type DocumentMode int
+
+const (
+	// DocumentAuto treats input starting with \documentclass as a full document.
+	DocumentAuto DocumentMode = iota
+	// DocumentBody transforms the input as it is.
+	DocumentBody
+	// DocumentFull expects a preamble followed by \begin{document}.
+	DocumentFull
+)
+
+var documentModeNames = []string{"auto", "body", "full"}
+
+func (m DocumentMode) MarshalText() ([]byte, error) {
+	return marshalName(documentModeNames, int(m), "document mode")
+}
+
+func (m *DocumentMode) UnmarshalText(text []byte) error {
+	value, err := unmarshalName(documentModeNames, string(text), "document mode")
+	*m = DocumentMode(value)
+	return err
+}
+
+func marshalName(names []string, value int, kind string) ([]byte, error) {
+	if value < 0 || value >= len(names) {
+		return nil, errors.New("unknown " + kind + " " + strconv.Itoa(value))
+	}
+	return []byte(names[value]), nil
+}
+
+func unmarshalName(names []string, name string, kind string) (int, error) {
+	for value, known := range names {
+		if known == name {
+			return value, nil
+		}
+	}
+	return 0, errors.New("unknown " + kind + " " + name)
+}
+
+// MathDelimiter selects the delimiters written around math.
+type MathDelimiter int
+
+const (
+	// DelimiterDefault keeps \(...\) for inline math and environments and \[...\] for display math.
+	DelimiterDefault MathDelimiter = iota
+	DelimiterParens
+	DelimiterBrackets
+	DelimiterDollar
+	DelimiterDoubleDollar
+	// DelimiterNone leaves math environments bare. For inline and display math it means the default.
+	DelimiterNone
+)
+
+var mathDelimiterNames = []string{"default", "parens", "brackets", "dollar", "double-dollar", "none"}
+
+func (d MathDelimiter) MarshalText() ([]byte, error) {
+	return marshalName(mathDelimiterNames, int(d), "math delimiter")
+}
+
+func (d *MathDelimiter) UnmarshalText(text []byte) error {
+	value, err := unmarshalName(mathDelimiterNames, string(text), "math delimiter")
+	*d = MathDelimiter(value)
+	return err
+}
+
+// pair returns the opening and closing delimiter, using fallback for the default and for none.
+func (d MathDelimiter) pair(fallback MathDelimiter) (string, string) {
+	if d == DelimiterDefault || d == DelimiterNone {
+		d = fallback
+	}
+	switch d {
+	case DelimiterBrackets:
+		return "\\[", "\\]"
+	case DelimiterDollar:
+		return "$", "$"
+	case DelimiterDoubleDollar:
+		return "$$", "$$"
+	default:
+		return "\\(", "\\)"
+	}
+}
+
+// Options selects optional transformation modes. The zero value reproduces TransformLatex.
+type Options struct {
+	// Profile names the registered target profile, DefaultProfile if empty.
+	Profile string `json:"profile,omitempty"`
+	// Images turns \includegraphics and figure environments into HTML.
+	Images bool `json:"images,omitempty"`
+	// SourceFile is the path of the transformed file, image paths are resolved relative to it.
+	SourceFile string `json:"sourceFile,omitempty"`
+	// EmbedImages references images as @@PLUGINFILE@@ and attaches them to the result.
+	EmbedImages bool `json:"embedImages,omitempty"`
+	// Language selects the headings of theorem-like environments, "de" (default) or "en".
+	Language string `json:"language,omitempty"`
+	// TheoremNames overrides single headings, e.g. "theorem": "Proposition".
+	TheoremNames map[string]string `json:"theoremNames,omitempty"`
+	// NumberTheorems numbers theorem-like environments with a shared counter.
+	NumberTheorems bool `json:"numberTheorems,omitempty"`
+	// TagEquations writes \tag{n} into every numbered equation row, so MathJax shows the same
+	// numbers as LaTeX even without labels.
+	TagEquations bool `json:"tagEquations,omitempty"`
+	// Flags sets the conditionals \if<flag>, "stack" is true unless overridden.
+	Flags map[string]bool `json:"flags,omitempty"`
+	// GermanShorthands converts babel shorthands such as "a and "` in text mode.
+	GermanShorthands bool `json:"germanShorthands,omitempty"`
+	// Preamble is the preamble of the document the input was taken from. Its definitions are
+	// used like those of a full document, loading babel with a German language there enables
+	// GermanShorthands.
+	Preamble string `json:"preamble,omitempty"`
+	// HTML decides whether the output is html.
+	HTML HTMLPolicy `json:"html,omitempty"`
+	// Comments decides what happens to % comments.
+	Comments CommentPolicy `json:"comments,omitempty"`
+	// InlineMath selects the delimiters of $...$ and \(...\).
+	InlineMath MathDelimiter `json:"inlineMath,omitempty"`
+	// DisplayMath selects the delimiters of $$...$$ and \[...\].
+	DisplayMath MathDelimiter `json:"displayMath,omitempty"`
+	// EnvironWrap selects how math environments outside of math such as align are wrapped.
+	EnvironWrap MathDelimiter `json:"environWrap,omitempty"`
+	// MathRelationCommands writes < and > in math as \lt and \gt, which html editors leave alone.
+	MathRelationCommands bool `json:"mathRelationCommands,omitempty"`
+	// Input configures the normalisation of the input.
+	Input InputOptions `json:"input,omitzero"`
+	// RootDir enables \input, \include and \subfile, the files are resolved relative to it.
+	RootDir string `json:"rootDir,omitempty"`
+	// Document decides whether the input is a full document whose preamble is not transformed.
+	Document DocumentMode `json:"document,omitempty"`
+}
@@ -0,0 +1,217 @@
+package latex
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// ListStyle selects how enumerate items are numbered.
+type ListStyle int
+
+const (
+	// ListLetters writes items as <li type="a">) like STACK expects.
+	ListLetters ListStyle = iota
+	// ListHTML writes plain <li> items in an <ol type="a">.
+	ListHTML
+)
+
+var listStyleNames = []string{"letters", "html"}
+
+func (s ListStyle) MarshalText() ([]byte, error) {
+	return marshalName(listStyleNames, int(s), "list style")
+}
+
+func (s *ListStyle) UnmarshalText(text []byte) error {
+	value, err := unmarshalName(listStyleNames, string(text), "list style")
+	*s = ListStyle(value)
+	return err
+}
+
+// OutputFormat selects the markup the transformation writes.
+type OutputFormat int
+
+const (
+	// FormatHTML writes LaTeX for MathJax with html for everything else.
+	FormatHTML OutputFormat = iota
+	// FormatMarkdown writes Markdown with LaTeX math, for notebooks and Markdown sites.
+	FormatMarkdown
+)
+
+var outputFormatNames = []string{"html", "markdown"}
+
+func (f OutputFormat) MarshalText() ([]byte, error) {
+	return marshalName(outputFormatNames, int(f), "output format")
+}
+
+func (f *OutputFormat) UnmarshalText(text []byte) error {
+	value, err := unmarshalName(outputFormatNames, string(text), "output format")
+	*f = OutputFormat(value)
+	return err
+}
+
+// Profile bundles the output settings for one platform. Settings in Options that are not the
+// zero value take precedence over the profile.
+type Profile struct {
+	Name        string        `json:"name"`
+	InlineMath  MathDelimiter `json:"inlineMath,omitempty"`
+	DisplayMath MathDelimiter `json:"displayMath,omitempty"`
+	EnvironWrap MathDelimiter `json:"environWrap,omitempty"`
+	HTML        HTMLPolicy    `json:"html,omitempty"`
+	Lists       ListStyle     `json:"lists,omitempty"`
+	Format      OutputFormat  `json:"format,omitempty"`
+	// AllowedTags maps the html tags the platform keeps to their allowed attributes.
+	AllowedTags map[string][]string `json:"allowedTags,omitempty"`
+	// Info is shown to the user when the output contains html.
+	Info string `json:"info,omitempty"`
+	// GermanShorthands enables Options.GermanShorthands for the platform's German questions.
+	GermanShorthands bool `json:"germanShorthands,omitempty"`
+}
+
+const DefaultProfile = "stack"
+
+// moodleTags is the part of the Moodle HTML purifier allow-list the transformation can produce.
+var moodleTags = map[string][]string{
+	"br": {}, "p": {"class"}, "div": {"class"}, "span": {"class"},
+	"strong": {}, "b": {}, "em": {}, "i": {}, "u": {}, "sub": {}, "sup": {"id"},
+	"ol": {"type", "class", "start"}, "ul": {"type"}, "li": {"type", "id", "value"},
+	"a": {"href", "id", "title"}, "img": {"src", "alt", "width", "height", "style", "title"},
+	"figure": {}, "figcaption": {}, "pre": {"class"}, "code": {"class"}, "hr": {},
+	"h1": {}, "h2": {}, "h3": {}, "h4": {}, "h5": {}, "h6": {},
+	"table": {"class"}, "thead": {}, "tbody": {}, "tr": {}, "th": {"colspan", "rowspan"}, "td": {"colspan", "rowspan"},
+}
+
+func getDefaultProfiles() []Profile {
+	return []Profile{
+		{
+			Name:        "stack",
+			AllowedTags: moodleTags,
+			Info:        "Output contains HTML.\nInput in Moodle as source code (Ansicht -> Quellcode)!",
+		},
+		{
+			Name:        "moodle",
+			Lists:       ListHTML,
+			AllowedTags: moodleTags,
+			Info:        "Output contains HTML.\nPaste it into the HTML source view of the Moodle editor.",
+		},
+		{
+			Name:        "ilias",
+			Lists:       ListHTML,
+			AllowedTags: moodleTags,
+			Info:        "Output contains HTML.\nPaste it into the HTML source of the ILIAS editor.",
+		},
+		{
+			Name:        "html",
+			EnvironWrap: DelimiterBrackets,
+			HTML:        HTMLAlways,
+			Lists:       ListHTML,
+		},
+		{
+			Name:        "markdown",
+			InlineMath:  DelimiterDollar,
+			DisplayMath: DelimiterDoubleDollar,
+			EnvironWrap: DelimiterDoubleDollar,
+			HTML:        HTMLNever,
+			Format:      FormatMarkdown,
+		},
+	}
+}
+
+var profileRegistry = struct {
+	sync.RWMutex
+	profiles map[string]Profile
+}{profiles: make(map[string]Profile)}
+
+func init() {
+	RegisterProfiles(getDefaultProfiles()...)
+}
+
+// RegisterProfiles adds profiles, replacing registered profiles of the same name.
+func RegisterProfiles(profiles ...Profile) {
+	profileRegistry.Lock()
+	defer profileRegistry.Unlock()
+	for _, profile := range profiles {
+		profileRegistry.profiles[profile.Name] = profile
+	}
+}
+
+func GetProfile(name string) (Profile, bool) {
+	if name == "" {
+		name = DefaultProfile
+	}
+	profileRegistry.RLock()
+	defer profileRegistry.RUnlock()
+	profile, ok := profileRegistry.profiles[name]
+	return profile, ok
+}
+
+// ProfileNames lists the registered profiles in alphabetical order.
+func ProfileNames() []string {
+	profileRegistry.RLock()
+	defer profileRegistry.RUnlock()
+	names := make([]string, 0, len(profileRegistry.profiles))
+	for name := range profileRegistry.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadProfiles reads a JSON array of profiles.
+func LoadProfiles(r io.Reader) ([]Profile, error) {
+	var profiles []Profile
+	if err := json.NewDecoder(r).Decode(&profiles); err != nil {
+		return nil, err
+	}
+	for _, profile := range profiles {
+		if profile.Name == "" {
+			return nil, errors.New("profile without name")
+		}
+	}
+	return profiles, nil
+}
+
+// DefaultProfileFile is the file in the working directory the frontends read additional target
+// profiles from.
+const DefaultProfileFile = "profiles.json"
+
+// RegisterProfileFile registers the profiles of a JSON file. A missing file is not an error.
+func RegisterProfileFile(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	profiles, err := LoadProfiles(file)
+	if err != nil {
+		return errors.New(path + ": " + err.Error())
+	}
+	RegisterProfiles(profiles...)
+	return nil
+}
+
+// applyTo fills the settings of options that are left at their zero value from the profile.
+func (p Profile) applyTo(options Options) Options {
+	if options.InlineMath == DelimiterDefault {
+		options.InlineMath = p.InlineMath
+	}
+	if options.DisplayMath == DelimiterDefault {
+		options.DisplayMath = p.DisplayMath
+	}
+	if options.EnvironWrap == DelimiterDefault {
+		options.EnvironWrap = p.EnvironWrap
+	}
+	if options.HTML == HTMLAuto {
+		options.HTML = p.HTML
+	}
+	if p.GermanShorthands {
+		options.GermanShorthands = true
+	}
+	return options
+}
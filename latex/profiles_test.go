@@ -0,0 +1,35 @@
+package latex
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfileFileGermanShorthands(t *testing.T) {
+	restoreProfiles(t)
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	if err := os.WriteFile(path, []byte(`[{"name": "test-german", "germanShorthands": true}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterProfileFile(path); err != nil {
+		t.Fatal(err)
+	}
+	input := "Gr\"o\"se und \"`Zitat\"'"
+	tests := []struct {
+		profile string
+		want    string
+	}{
+		{"test-german", "Größe und „Zitat“"},
+		{DefaultProfile, "Gr\"o\"se und \"‘Zitat\"’"},
+	}
+	for _, test := range tests {
+		result := TransformLatexWithOptions(input, Options{Profile: test.profile})
+		if !result.Success {
+			t.Fatal(result.ErrorMessage)
+		}
+		if result.Transformed != test.want {
+			t.Errorf("profile %s: Transformed = %q, want %q", test.profile, result.Transformed, test.want)
+		}
+	}
+}
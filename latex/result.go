@@ -0,0 +1,61 @@
+package latex
+
+import (
+	"sort"
+	"strconv"
+)
+
+// LogEntry counts how often an operation was applied.
+type LogEntry struct {
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+func (e LogEntry) String() string {
+	return strconv.Itoa(e.Count) + "x " + e.Message
+}
+
+// Report describes a transformation apart from its output.
+type Report struct {
+	// Log lists the applied operations, sorted by message.
+	Log []LogEntry `json:"log"`
+	// Info is a hint for the user, e.g. that the output has to be pasted as html source.
+	Info        string         `json:"info,omitempty"`
+	Files       []EmbeddedFile `json:"files,omitempty"`
+	Diagnostics []Diagnostic   `json:"diagnostics"`
+}
+
+// LogText returns the log with one "<count>x <message>" line per entry.
+func (r Report) LogText() string {
+	logString := ""
+	for _, entry := range r.Log {
+		logString += entry.String() + "\n"
+	}
+	return logString
+}
+
+// Result is the result of TransformLatex and ReverseTransform.
+type Result struct {
+	Transformed  string `json:"transformed"`
+	Success      bool   `json:"success"`
+	ErrorMessage string `json:"error,omitempty"`
+	Report
+}
+
+func failedResult(err error, report Report) Result {
+	if report.Diagnostics == nil {
+		report.Diagnostics = []Diagnostic{}
+	}
+	return Result{Success: false, ErrorMessage: err.Error(), Report: report}
+}
+
+func logEntries(logMap map[string]int) []LogEntry {
+	entries := make([]LogEntry, 0, len(logMap))
+	for message, count := range logMap {
+		entries = append(entries, LogEntry{message, count})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Message < entries[j].Message
+	})
+	return entries
+}
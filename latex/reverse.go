@@ -0,0 +1,449 @@
+package latex
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// ReverseOptions control how ReverseTransform writes LaTeX.
+type ReverseOptions struct {
+	// InlineMath selects the delimiters of inline math, $...$ by default.
+	InlineMath MathDelimiter `json:"inlineMath,omitempty"`
+	// DisplayMath selects the delimiters of display math, \[...\] by default.
+	DisplayMath MathDelimiter `json:"displayMath,omitempty"`
+}
+
+// mathDelimiterPairs are the delimiters of math in the transformed text.
+var mathDelimiterPairs = map[string]string{
+	"\\(": "\\)",
+	"\\[": "\\]",
+}
+
+type tagReplacement struct {
+	leftRepl  string
+	rightRepl string
+}
+
+// GetReverseTagReplacements maps html tags without special handling to LaTeX.
+func GetReverseTagReplacements() map[string]tagReplacement {
+	return map[string]tagReplacement{
+		"strong":     {"\\textbf{", "}"},
+		"b":          {"\\textbf{", "}"},
+		"em":         {"\\emph{", "}"},
+		"i":          {"\\textit{", "}"},
+		"u":          {"\\underline{", "}"},
+		"sub":        {"\\textsubscript{", "}"},
+		"sup":        {"\\textsuperscript{", "}"},
+		"h1":         {"\\section*{", "}"},
+		"h2":         {"\\subsection*{", "}"},
+		"h3":         {"\\subsubsection*{", "}"},
+		"p":          {"", "\n\n"},
+		"span":       {"", ""},
+		"ol":         {"\\begin{enumerate}", "\\end{enumerate}"},
+		"ul":         {"\\begin{itemize}", "\\end{itemize}"},
+		"figure":     {"\\begin{figure}", "\\end{figure}"},
+		"figcaption": {"\\caption{", "}"},
+	}
+}
+
+type openTag struct {
+	name      string
+	rightRepl string
+	trim      string // text the transformation appended before the closing tag
+}
+
+type reverseState struct {
+	options      ReverseOptions
+	tags         map[string]tagReplacement
+	output       string
+	pending      string // text that still contains \( \) \[ \] delimiters
+	openTags     []openTag
+	footnotes    map[string]string
+	code         *string
+	codeLanguage string
+	inPre        bool
+	trimPrefix   string
+	logMap       map[string]int
+}
+
+func (r *reverseState) log(s string) {
+	r.logMap[s] += 1
+}
+
+// ReverseTransform turns the output of TransformLatex back into LaTeX.
+func ReverseTransform(transformed string, options ReverseOptions) Result {
+	for _, delimiter := range []MathDelimiter{options.InlineMath, options.DisplayMath} {
+		if _, err := delimiter.MarshalText(); err != nil {
+			return failedResult(err, Report{})
+		}
+	}
+	state := &reverseState{options: options, tags: GetReverseTagReplacements(), footnotes: make(map[string]string), logMap: make(map[string]int)}
+	if err := state.reverse(transformed); err != nil {
+		return failedResult(err, Report{})
+	}
+	return Result{Transformed: state.output, Success: true, Report: Report{Log: logEntries(state.logMap), Diagnostics: []Diagnostic{}}}
+}
+
+// reverse writes the LaTeX of transformed to the output of the state.
+func (s *reverseState) reverse(transformed string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("internal error: %v", r)
+		}
+	}()
+	transformed = s.stripPreamble(transformed)
+	if !isTransformedHTML(transformed) {
+		s.pending = strings.ReplaceAll(transformed, "\u00a0", "~")
+		return s.flush()
+	}
+	tokens, err := s.collectFootnotes(tokenizeHTML(transformed))
+	if err != nil {
+		return err
+	}
+	return s.reverseTokens(tokens)
+}
+
+func isTransformedHTML(s string) bool {
+	for _, token := range tokenizeHTML(s) {
+		if token.ttype != htmlText {
+			return true
+		}
+	}
+	return false
+}
+
+// stripPreamble removes the custom command definitions that TransformLatex puts in front. They
+// are wrapped in the inline math delimiters of the transformation.
+func (r *reverseState) stripPreamble(s string) string {
+	for _, delimiter := range []MathDelimiter{DelimiterParens, DelimiterBrackets, DelimiterDoubleDollar, DelimiterDollar} {
+		open, close := delimiter.pair(DelimiterParens)
+		definitions, found := strings.CutPrefix(s, open+"\\newcommand")
+		if !found {
+			continue
+		}
+		_, after, found := strings.Cut(definitions, close)
+		if !found {
+			return s
+		}
+		r.log("Removed custom command definitions")
+		return after
+	}
+	return s
+}
+
+// collectFootnotes removes the footnote list at the end and stores the LaTeX of each footnote.
+func (r *reverseState) collectFootnotes(tokens []htmlToken) ([]htmlToken, error) {
+	start := -1
+	for i, token := range tokens {
+		if class, _ := token.attribute("class"); token.ttype == htmlStartTag && token.name == "ol" && class == "footnotes" {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return tokens, nil
+	}
+	rest := tokens[0:start]
+	if start > 0 && tokens[start-1].ttype == htmlStartTag && tokens[start-1].name == "hr" {
+		rest = tokens[0 : start-1]
+	}
+	for i := start + 1; i < len(tokens); i++ {
+		token := tokens[i]
+		if token.ttype == htmlEndTag && token.name == "ol" {
+			break
+		}
+		id, _ := token.attribute("id")
+		if token.ttype != htmlStartTag || token.name != "li" || !strings.HasPrefix(id, "fn-") {
+			continue
+		}
+		bodyStart := i + 1
+		for i < len(tokens) {
+			href, _ := tokens[i].attribute("href")
+			if tokens[i].ttype == htmlStartTag && tokens[i].name == "a" && href == "#fnref-"+id[3:] {
+				break
+			}
+			i++
+		}
+		body := &reverseState{options: r.options, tags: r.tags, footnotes: r.footnotes, logMap: r.logMap}
+		if err := body.reverseTokens(tokens[bodyStart:i]); err != nil {
+			return nil, err
+		}
+		r.footnotes[id[3:]] = strings.TrimSuffix(body.output, " ")
+		for i < len(tokens) && !(tokens[i].ttype == htmlEndTag && tokens[i].name == "li") {
+			i++
+		}
+	}
+	return rest, nil
+}
+
+func (r *reverseState) reverseTokens(tokens []htmlToken) error {
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		switch token.ttype {
+		case htmlText:
+			r.addText(token.text)
+		case htmlStartTag:
+			i += r.startTag(token, tokens[i+1:])
+		case htmlEndTag:
+			if err := r.endTag(token.name); err != nil {
+				return err
+			}
+		}
+	}
+	return r.flush()
+}
+
+func (r *reverseState) addText(text string) {
+	text = html.UnescapeString(strings.ReplaceAll(text, "&nbsp;", "~"))
+	if r.code != nil {
+		*r.code += text
+		return
+	}
+	text = strings.TrimPrefix(text, r.trimPrefix)
+	r.trimPrefix = ""
+	r.pending += strings.ReplaceAll(text, "\u00a0", "~")
+}
+
+// startTag writes the LaTeX for a start tag and returns how many of the following tokens it consumed.
+func (r *reverseState) startTag(token htmlToken, following []htmlToken) int {
+	class, _ := token.attribute("class")
+	id, _ := token.attribute("id")
+	switch {
+	case token.name == "br":
+		if len(following) == 0 || following[0].ttype != htmlText || !strings.HasPrefix(following[0].text, "\n") {
+			r.pending += "\n"
+		}
+		return 0
+	case token.name == "pre":
+		r.inPre = true
+		r.codeLanguage = ""
+		return 0
+	case token.name == "code":
+		code := ""
+		r.code = &code
+		if r.inPre {
+			r.codeLanguage = strings.TrimPrefix(class, "language-")
+		}
+		return 0
+	case token.name == "sup" && strings.HasPrefix(id, "fnref-"):
+		r.log("Replaced footnote marker with \\footnote")
+		r.pending += "\\footnote{" + r.footnotes[strings.TrimPrefix(id, "fnref-")] + "}"
+		for i, next := range following {
+			if next.ttype == htmlEndTag && next.name == "sup" {
+				return i + 1
+			}
+		}
+		return len(following)
+	case token.name == "div" && class != "":
+		return r.startEnviron(class, following)
+	case token.name == "li":
+		r.log("Replaced <li> with \\item")
+		r.pending += "\\item"
+		if _, ok := token.attribute("type"); ok {
+			r.trimPrefix = ")"
+		}
+		r.openTags = append(r.openTags, openTag{name: "li"})
+		return 0
+	case token.name == "a":
+		href, _ := token.attribute("href")
+		if href == "" || strings.HasPrefix(href, "#") {
+			r.openTags = append(r.openTags, openTag{name: "a"})
+		} else {
+			r.log("Replaced <a> with \\href")
+			r.pending += "\\href{" + href + "}{"
+			r.openTags = append(r.openTags, openTag{name: "a", rightRepl: "}"})
+		}
+		return 0
+	case token.name == "img":
+		r.log("Replaced <img> with \\includegraphics")
+		r.pending += "\\includegraphics{" + imageName(token) + "}"
+		return 0
+	}
+	repl, ok := r.tags[token.name]
+	if !ok {
+		r.log("Removed <" + token.name + ">")
+		if !isVoidElement(token.name) && !token.selfClosing {
+			r.openTags = append(r.openTags, openTag{name: token.name})
+		}
+		return 0
+	}
+	r.log("Replaced <" + token.name + "> with " + repl.leftRepl + "..." + repl.rightRepl)
+	r.pending += repl.leftRepl
+	r.openTags = append(r.openTags, openTag{name: token.name, rightRepl: repl.rightRepl})
+	return 0
+}
+
+// startEnviron turns a theorem like div back into its environment, reading the title from the heading.
+func (r *reverseState) startEnviron(class string, following []htmlToken) int {
+	repl, ok := GetEnvReplacements()[class]
+	if !ok || repl.Heading == "" {
+		r.log("Removed <div class=\"" + class + "\">")
+		r.openTags = append(r.openTags, openTag{name: "div"})
+		return 0
+	}
+	r.log("Replaced <div class=\"" + class + "\"> with environment " + class)
+	r.pending += "\\begin{" + class + "}"
+	r.openTags = append(r.openTags, openTag{
+		name:      "div",
+		rightRepl: "\\end{" + class + "}",
+		trim:      html.UnescapeString(strings.TrimSuffix(repl.RightRepl, "</div>")),
+	})
+	if len(following) < 3 || following[0].name != "strong" || following[1].ttype != htmlText || following[2].name != "strong" {
+		return 0
+	}
+	heading := strings.TrimSuffix(strings.TrimSpace(html.UnescapeString(following[1].text)), ".")
+	if open := strings.Index(heading, " ("); open >= 0 && strings.HasSuffix(heading, ")") {
+		r.pending += "[" + heading[open+2:len(heading)-1] + "]"
+	}
+	r.trimPrefix = " "
+	return 3
+}
+
+func imageName(token htmlToken) string {
+	src, _ := token.attribute("src")
+	alt, _ := token.attribute("alt")
+	if strings.HasPrefix(src, "data:") || strings.HasPrefix(src, "@@PLUGINFILE@@/") {
+		return alt
+	}
+	return src
+}
+
+func (r *reverseState) endTag(name string) error {
+	switch name {
+	case "code":
+		if r.code == nil {
+			return nil
+		}
+		if r.inPre {
+			return nil
+		}
+		code := *r.code
+		r.code = nil
+		r.log("Replaced <code> with \\verb")
+		return r.addLiteral("\\verb" + verbDelimiter(code) + code + verbDelimiter(code))
+	case "pre":
+		r.inPre = false
+		return r.endPre()
+	}
+	match := -1
+	for i := len(r.openTags) - 1; i >= 0; i-- {
+		if r.openTags[i].name == name {
+			match = i
+			break
+		}
+	}
+	if match < 0 {
+		return nil
+	}
+	for i := len(r.openTags) - 1; i >= match; i-- {
+		tag := r.openTags[i]
+		if tag.trim != "" {
+			r.pending = strings.TrimSuffix(r.pending, tag.trim)
+		}
+		r.pending += tag.rightRepl
+	}
+	r.openTags = r.openTags[0:match]
+	return nil
+}
+
+func (r *reverseState) endPre() error {
+	code := ""
+	if r.code != nil {
+		code = *r.code
+		r.code = nil
+	}
+	if r.codeLanguage != "" {
+		r.log("Replaced <pre><code> with environment lstlisting")
+		return r.addLiteral("\\begin{lstlisting}[language=" + r.codeLanguage + "]\n" + code + "\n\\end{lstlisting}")
+	}
+	r.log("Replaced <pre><code> with environment verbatim")
+	return r.addLiteral("\\begin{verbatim}\n" + code + "\n\\end{verbatim}")
+}
+
+func verbDelimiter(code string) string {
+	for _, delimiter := range []string{"|", "!", "+", "#", "@"} {
+		if !strings.Contains(code, delimiter) {
+			return delimiter
+		}
+	}
+	return "\""
+}
+
+// addLiteral appends text that must not be searched for math delimiters.
+func (r *reverseState) addLiteral(s string) error {
+	if err := r.flush(); err != nil {
+		return err
+	}
+	r.output += s
+	return nil
+}
+
+// flush converts the math delimiters of the pending text and appends it to the output.
+func (r *reverseState) flush() error {
+	s := r.pending
+	r.pending = ""
+	for {
+		open := indexMathDelimiter(s, "\\(", "\\[")
+		if open < 0 {
+			r.output += s
+			return nil
+		}
+		r.output += s[0:open]
+		opening := s[open : open+2]
+		closing := mathDelimiterPairs[opening]
+		length := indexMathDelimiter(s[open+2:], closing)
+		if length < 0 {
+			return errors.New("math mode is not closed")
+		}
+		r.output += r.reverseMath(opening, s[open+2:open+2+length])
+		s = s[open+2+length+2:]
+	}
+}
+
+// indexMathDelimiter returns the index of the first of the delimiters that is not itself escaped.
+func indexMathDelimiter(s string, delimiters ...string) int {
+	for i := 0; i+1 < len(s); i++ {
+		if s[i] != '\\' {
+			continue
+		}
+		for _, delimiter := range delimiters {
+			if strings.HasPrefix(s[i:], delimiter) {
+				return i
+			}
+		}
+		i++
+	}
+	return -1
+}
+
+func (r *reverseState) reverseMath(opening string, math string) string {
+	trimmed := strings.TrimSpace(math)
+	if opening == "\\(" && trimmed == "\\\\" {
+		r.log("Unwrapped newline \\\\")
+		return "\\\\"
+	}
+	if environ, ok := wrappedEnviron(trimmed); ok {
+		r.log("Unwrapped environment " + environ)
+		return math
+	}
+	open, close := r.options.InlineMath.pair(DelimiterDollar)
+	if opening == "\\[" {
+		open, close = r.options.DisplayMath.pair(DelimiterBrackets)
+	}
+	r.log("Replaced " + opening + "..." + mathDelimiterPairs[opening] + " with " + open + "..." + close)
+	return open + math + close
+}
+
+// wrappedEnviron reports whether math consists of exactly one math environment.
+func wrappedEnviron(math string) (string, bool) {
+	if !strings.HasPrefix(math, "\\begin{") {
+		return "", false
+	}
+	environ, _, found := strings.Cut(math[len("\\begin{"):], "}")
+	if !found || !GetKnownMathEnvirons()[environ] || !strings.HasSuffix(math, "\\end{"+environ+"}") {
+		return "", false
+	}
+	return environ, true
+}
@@ -0,0 +1,75 @@
+package latex
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReverseTransform(t *testing.T) {
+	tests := []struct {
+		html    string
+		options ReverseOptions
+		want    string
+	}{
+		{"a &lt; b &amp; c<br>\nx&nbsp;y", ReverseOptions{}, "a < b & c\nx~y"},
+		{"one<br>\ntwo", ReverseOptions{}, "one\ntwo"},
+		{"<ul>\n<li> a</li>\n<li> b</li>\n</ul>", ReverseOptions{}, "\\begin{itemize}\n\\item a\n\\item b\n\\end{itemize}"},
+		{"<ol type=\"a\">\n<li> x</li>\n</ol>", ReverseOptions{}, "\\begin{enumerate}\n\\item x\n\\end{enumerate}"},
+		{"<strong>bold</strong> and <em>it</em>", ReverseOptions{}, "\\textbf{bold} and \\emph{it}"},
+		{"Let \\(x\\) and \\[y\\]", ReverseOptions{}, "Let $x$ and \\[y\\]"},
+		{"Let \\(x\\) and \\[y\\]", ReverseOptions{InlineMath: DelimiterParens, DisplayMath: DelimiterDoubleDollar}, "Let \\(x\\) and $$y$$"},
+		{"\\(\\newcommand{\\abs}[1]{\\left|#1\\right|} \\)\\(\\abs{x}\\)", ReverseOptions{}, "$\\abs{x}$"},
+		{"$\\newcommand{\\abs}[1]{\\left|#1\\right|} $$\\abs{x}$", ReverseOptions{}, "$\\abs{x}$"},
+		{"$$\\newcommand{\\abs}[1]{\\left|#1\\right|} $$\\[\\abs{x}\\]", ReverseOptions{}, "\\[\\abs{x}\\]"},
+		{"\\[\\newcommand{\\abs}[1]{\\left|#1\\right|} \\]\\(\\abs{x}\\)", ReverseOptions{}, "$\\abs{x}$"},
+	}
+	for _, test := range tests {
+		result := ReverseTransform(test.html, test.options)
+		if !result.Success {
+			t.Errorf("ReverseTransform(%q) failed: %s", test.html, result.ErrorMessage)
+		} else if result.Transformed != test.want {
+			t.Errorf("ReverseTransform(%q) = %q, want %q", test.html, result.Transformed, test.want)
+		}
+	}
+}
+
+func TestReverseTransformRejectsUnknownDelimiter(t *testing.T) {
+	result := ReverseTransform("\\(x\\)", ReverseOptions{InlineMath: MathDelimiter(42)})
+	if result.Success || result.ErrorMessage != "unknown math delimiter 42" {
+		t.Errorf("ReverseTransform = %+v, want unknown math delimiter error", result)
+	}
+}
+
+func TestReverseTransformRoundTrip(t *testing.T) {
+	inputs := []string{
+		"\\begin{itemize}\n\\item a\n\\item b\n\\end{itemize}",
+		"\\begin{enumerate}\n\\item $x<y$\n\\end{enumerate}",
+		"Let $x$ and \\[y\\]",
+		"one\n\ntwo",
+		"\\textbf{a} and 1 < 2 \\& 3",
+		"Footnote\\footnote{see $z$}.",
+	}
+	for _, input := range inputs {
+		transformed := TransformLatex(input)
+		if !transformed.Success {
+			t.Fatalf("TransformLatex(%q) failed: %s", input, transformed.ErrorMessage)
+		}
+		result := ReverseTransform(transformed.Transformed, ReverseOptions{})
+		if result.Transformed != input {
+			t.Errorf("ReverseTransform(TransformLatex(%q)) = %q", input, result.Transformed)
+		}
+	}
+}
+
+func TestReverseTransformStripsPreamble(t *testing.T) {
+	for _, delimiter := range []MathDelimiter{DelimiterDefault, DelimiterParens, DelimiterBrackets, DelimiterDollar, DelimiterDoubleDollar} {
+		transformed := TransformLatexWithOptions("Let $\\abs{x}$ be", Options{InlineMath: delimiter})
+		if !transformed.Success {
+			t.Fatal(transformed.ErrorMessage)
+		}
+		result := ReverseTransform(transformed.Transformed, ReverseOptions{InlineMath: delimiter})
+		if strings.Contains(result.Transformed, "\\newcommand") {
+			t.Errorf("ReverseTransform(%q) = %q, want the definitions removed", transformed.Transformed, result.Transformed)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package latex_test
+
+import (
+	"testing"
+
+	"stacklatex/latex"
+)
+
+func TestExtendRuleSet(t *testing.T) {
+	rules := latex.DefaultRules()
+	rules.CommandReplacements["N"] = latex.CommandReplacement{EscapeRepl: true, LeftRepl: "\\mathbb{N}"}
+	rules.CommandReplacements["hl"] = latex.CommandReplacement{ArgCommand: true, LeftRepl: "<mark>", RightRepl: "</mark>"}
+	rules.EnvReplacements["note"] = latex.EnvReplacement{LeftRepl: "<aside>", RightRepl: "</aside>"}
+	rules.TextAccents["G"] = latex.TextAccent{Combining: '\u030f', Precomposed: "aȁAȀeȅEȄ"}
+	result := latex.NewTransformer(rules, latex.Options{}).TransformString("\\begin{note}$x \\in \\N$ \\hl{\\G{a}} \\G{x}\\end{note}")
+	if !result.Success {
+		t.Fatal(result.ErrorMessage)
+	}
+	want := "<aside>\\(x \\in \\mathbb{N}\\) <mark>ȁ</mark> x\u030f</aside>"
+	if result.Transformed != want {
+		t.Errorf("Transformed = %q, want %q", result.Transformed, want)
+	}
+}
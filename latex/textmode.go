@@ -0,0 +1,293 @@
+package latex
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+type accentState struct {
+	accent string
+	group  bool
+	base   string
+}
+
+// TextAccent turns an accent command into precomposed or combining characters for RuleSet.TextAccents.
+type TextAccent struct {
+	Combining   rune   // combining character used for letters without a precomposed form
+	Precomposed string // pairs of base letter and accented letter
+}
+
+func GetTextAccents() map[string]TextAccent {
+	return map[string]TextAccent{
+		"\"": {'\u0308', "aäAÄeëEËiïIÏoöOÖuüUÜyÿYŸ"},
+		"'":  {'\u0301', "aáAÁeéEÉiíIÍoóOÓuúUÚyýYÝcćCĆnńNŃsśSŚzźZŹlĺLĹrŕRŔ"},
+		"`":  {'\u0300', "aàAÀeèEÈiìIÌoòOÒuùUÙ"},
+		"^":  {'\u0302', "aâAÂeêEÊiîIÎoôOÔuûUÛcĉCĈgĝGĜhĥHĤjĵJĴsŝSŜwŵWŴyŷYŶ"},
+		"~":  {'\u0303', "aãAÃnñNÑoõOÕiĩIĨuũUŨ"},
+		"=":  {'\u0304', "aāAĀeēEĒiīIĪoōOŌuūUŪ"},
+		".":  {'\u0307', "zżZŻeėEĖcċCĊgġGĠIİ"},
+		"c":  {'\u0327', "cçCÇsşSŞtţTŢgģGĢkķKĶlļLĻnņNŅrŗRŖ"},
+		"v":  {'\u030c', "cčCČsšSŠzžZŽrřRŘeěEĚnňNŇdďDĎtťTŤ"},
+		"u":  {'\u0306', "aăAĂgğGĞuŭUŬ"},
+		"H":  {'\u030b', "oőOŐuűUŰ"},
+		"r":  {'\u030a', "aåAÅuůUŮ"},
+		"k":  {'\u0328', "aąAĄeęEĘiįIĮuųUŲ"},
+		"d":  {'\u0323', ""},
+		"b":  {'\u0331', ""},
+	}
+}
+
+func GetTextSymbols() map[string]string {
+	return map[string]string{
+		"ss":                "ß",
+		"SS":                "SS",
+		"o":                 "ø",
+		"O":                 "Ø",
+		"aa":                "å",
+		"AA":                "Å",
+		"ae":                "æ",
+		"AE":                "Æ",
+		"oe":                "œ",
+		"OE":                "Œ",
+		"l":                 "ł",
+		"L":                 "Ł",
+		"i":                 "ı",
+		"j":                 "ȷ",
+		"textendash":        "–",
+		"textemdash":        "—",
+		"ldots":             "…",
+		"dots":              "…",
+		"textellipsis":      "…",
+		"S":                 "§",
+		"P":                 "¶",
+		"copyright":         "©",
+		"textregistered":    "®",
+		"texttrademark":     "™",
+		"pounds":            "£",
+		"euro":              "€",
+		"texteuro":          "€",
+		"textdegree":        "°",
+		"dag":               "†",
+		"ddag":              "‡",
+		"textbullet":        "•",
+		"textperthousand":   "‰",
+		"textquoteleft":     "‘",
+		"textquoteright":    "’",
+		"textquotedblleft":  "“",
+		"textquotedblright": "”",
+		"guillemotleft":     "«",
+		"guillemotright":    "»",
+		"textbackslash":     "\\",
+		"textasciitilde":    "~",
+		"textunderscore":    "_",
+		"textbar":           "|",
+	}
+}
+
+func isNonLetterAccent(char rune) bool {
+	return strings.ContainsRune("\"'`^~=.", char)
+}
+
+func (l *latexTransformationInfo) isTextMode() bool {
+	return !l.isMathModeActive() && l.getEnv() == noMathEnv
+}
+
+func (l *latexTransformationInfo) getTextAccent(accent string) (TextAccent, bool) {
+	val, ok := l.textAccents[accent]
+	return val, ok
+}
+
+func (l *latexTransformationInfo) getTextSymbol(command string) (string, bool) {
+	val, ok := l.textSymbols[command]
+	return val, ok
+}
+
+func startAccent(info *latexTransformationInfo, accent string) {
+	info.accent = accentState{accent: accent}
+	info.setPrevToken(token{accentArg, ""})
+}
+
+func handlePrevAccentArg(char rune, info *latexTransformationInfo) error {
+	state := &info.accent
+	switch {
+	case state.group:
+		if char == '}' {
+			finishAccent(info)
+		} else {
+			state.base += string(char)
+		}
+	case strings.HasPrefix(state.base, "\\"):
+		// control word argument such as \i
+		if unicode.IsLetter(char) {
+			state.base += string(char)
+			return nil
+		}
+		finishAccent(info)
+		if char != ' ' {
+			return handleCharacter(char, info)
+		}
+	case char == ' ':
+	case char == '{':
+		state.group = true
+	case char == '\\':
+		state.base = "\\"
+	default:
+		state.base = string(char)
+		finishAccent(info)
+	}
+	return nil
+}
+
+func finishAccent(info *latexTransformationInfo) {
+	state := info.accent
+	info.setPrevToken(token{none, ""})
+	accent, _ := info.getTextAccent(state.accent)
+	base := strings.TrimSpace(state.base)
+	switch base {
+	case "\\i":
+		base = "i"
+	case "\\j":
+		base = "j"
+	}
+	info.log("Replaced text accent \\" + state.accent + " with Unicode")
+	if base == "" {
+		info.addToOutputString(string(accent.Combining))
+		return
+	}
+	runes := []rune(base)
+	pairs := []rune(accent.Precomposed)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if len(runes) == 1 && pairs[i] == runes[0] {
+			info.addToOutputString(string(pairs[i+1]))
+			return
+		}
+	}
+	info.addToOutputString(string(runes[0]) + string(accent.Combining) + string(runes[1:]))
+}
+
+func handleTextSymbol(command string, char rune, info *latexTransformationInfo) error {
+	symbol, _ := info.getTextSymbol(command)
+	info.log("Replaced \\" + command + " with " + symbol)
+	if symbol == "\\" {
+		// a literal backslash could form a command with the following text
+		info.useHtml()
+		info.addRawToOutputString(backslashPlaceholder)
+	} else {
+		info.addToOutputString(symbol)
+	}
+	// like TeX, a space or an empty group after the control word is dropped
+	info.setPrevToken(token{textSymbolEnd, ""})
+	return handleCharacter(char, info)
+}
+
+func handlePrevTextSymbolEnd(char rune, info *latexTransformationInfo) error {
+	if info.getTokenInfo() == "{" {
+		info.setPrevToken(token{none, ""})
+		if char == '}' {
+			return nil
+		}
+		err := handleCharacter('{', info)
+		if err != nil {
+			return err
+		}
+		return handleCharacter(char, info)
+	}
+	switch char {
+	case ' ':
+		info.setPrevToken(token{none, ""})
+	case '{':
+		info.addTokenInfo("{")
+	default:
+		info.setPrevToken(token{none, ""})
+		return handleCharacter(char, info)
+	}
+	return nil
+}
+
+var ligatures = map[string]string{
+	"--":  "–",
+	"---": "—",
+	"``":  "“",
+	"''":  "”",
+	"`":   "‘",
+	"'":   "’",
+}
+
+func handlePrevLigature(char rune, info *latexTransformationInfo) error {
+	sequence := info.getTokenInfo()
+	maxLength := 2
+	if sequence[0] == '-' {
+		maxLength = 3
+	}
+	if rune(sequence[0]) == char && len(sequence) < maxLength {
+		info.addTokenInfo(string(char))
+		return nil
+	}
+	info.setPrevToken(token{none, ""})
+	if replacement, ok := ligatures[sequence]; ok {
+		info.log("Replaced " + sequence + " with " + replacement)
+		info.addToOutputString(replacement)
+	} else {
+		info.addToOutputString(sequence)
+	}
+	return handleCharacter(char, info)
+}
+
+func handleTilde(info *latexTransformationInfo) {
+	info.log("Replaced ~ with non-breaking space")
+	info.addRawToOutputString(nbspPlaceholder)
+}
+
+func GetGermanShorthands() map[rune]string {
+	return map[rune]string{
+		'a':  "ä",
+		'o':  "ö",
+		'u':  "ü",
+		'e':  "ë",
+		'i':  "ï",
+		'A':  "Ä",
+		'O':  "Ö",
+		'U':  "Ü",
+		's':  "ß",
+		'z':  "ß",
+		'S':  "SS",
+		'Z':  "SZ",
+		'`':  "„",
+		'\'': "“",
+		'<':  "«",
+		'>':  "»",
+		'-':  "\u00ad",
+		'"':  "\u200b",
+		'~':  "\u2011",
+		'=':  "-",
+		'|':  "\u200c",
+	}
+}
+
+var babelOptionsPattern = regexp.MustCompile(`\\usepackage\s*\[([^\]]*)\]\s*\{babel\}`)
+
+// usesGermanBabel reports whether a preamble loads babel with a German language option.
+func usesGermanBabel(preamble string) bool {
+	for _, match := range babelOptionsPattern.FindAllStringSubmatch(preamble, -1) {
+		for _, option := range strings.Split(match[1], ",") {
+			switch strings.TrimSpace(option) {
+			case "ngerman", "german", "naustrian", "austrian", "nswissgerman", "swissgerman":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func handlePrevBabelShorthand(char rune, info *latexTransformationInfo) error {
+	info.setPrevToken(token{none, ""})
+	replacement, ok := info.germanShorthands[char]
+	if !ok {
+		info.addToOutputString("\"")
+		return handleCharacter(char, info)
+	}
+	info.log("Replaced babel shorthand \"" + string(char) + " with " + replacement)
+	info.addToOutputString(replacement)
+	return nil
+}
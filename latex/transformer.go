@@ -0,0 +1,119 @@
+package latex
+
+import (
+	"errors"
+	"io"
+	"maps"
+	"strings"
+	"sync"
+)
+
+// RuleSet holds the tables that drive a transformation. Start from DefaultRules to change single entries.
+type RuleSet struct {
+	CustomCommands            map[string]string
+	CustomCommandOrder        []string
+	CustomCommandDependencies map[string][]string
+	CommandReplacements       map[string]CommandReplacement
+	EnvReplacements           map[string]EnvReplacement
+	KnownMathEnvirons         map[string]bool
+	VerbatimEnvirons          map[string]VerbatimArg
+	CommentEnvirons           map[string]bool
+	TextAccents               map[string]TextAccent
+	TextSymbols               map[string]string
+	MathJaxCommands           map[string]bool
+	MathJaxEnvirons           map[string]bool
+	MathOnlyCommands          map[string]bool
+	MathSymbols               map[rune]string
+}
+
+func DefaultRules() RuleSet {
+	return RuleSet{
+		CustomCommands:            GetCustomCommands(),
+		CustomCommandOrder:        customCommandsInOrder(),
+		CustomCommandDependencies: customCommandDependencies(),
+		CommandReplacements:       GetCommandReplacements(),
+		EnvReplacements:           GetEnvReplacements(),
+		KnownMathEnvirons:         GetKnownMathEnvirons(),
+		VerbatimEnvirons:          GetVerbatimEnvirons(),
+		CommentEnvirons:           GetCommentEnvirons(),
+		TextAccents:               GetTextAccents(),
+		TextSymbols:               GetTextSymbols(),
+		MathJaxCommands:           GetMathJaxCommands(),
+		MathJaxEnvirons:           GetMathJaxEnvirons(),
+		MathOnlyCommands:          GetMathOnlyCommands(),
+		MathSymbols:               GetMathSymbols(),
+	}
+}
+
+// Transformer transforms LaTeX with a fixed rule set and options. The tables are prepared once
+// and only read afterwards, so a Transformer can be used from many goroutines.
+type Transformer struct {
+	rules            RuleSet
+	options          Options
+	rawArgCommands   map[string]rawArgCommand
+	theoremNames     map[string]string
+	flags            map[string]bool
+	germanShorthands map[rune]string
+	profile          Profile
+	err              error // reported by every transformation
+}
+
+// NewTransformer prepares a Transformer. The maps of rules are not modified.
+func NewTransformer(rules RuleSet, options Options) *Transformer {
+	profile, found := GetProfile(options.Profile)
+	options = profile.applyTo(options)
+	t := &Transformer{
+		profile:        profile,
+		rules:          rules,
+		options:        options,
+		rawArgCommands: getLabelRawArgCommands(),
+		theoremNames:   GetTheoremNames(options.Language),
+		flags:          GetDefaultFlags(),
+	}
+	if !found {
+		t.err = errors.New("unknown profile " + options.Profile)
+	}
+	if options.GermanShorthands || usesGermanBabel(options.Preamble) {
+		t.germanShorthands = GetGermanShorthands()
+	}
+	maps.Copy(t.flags, options.Flags)
+	maps.Copy(t.theoremNames, options.TheoremNames)
+	if options.RootDir != "" {
+		maps.Copy(t.rawArgCommands, getIncludeRawArgCommands())
+	}
+	if options.Images {
+		t.rules.EnvReplacements = maps.Clone(rules.EnvReplacements)
+		maps.Copy(t.rules.EnvReplacements, GetFigureEnvReplacements())
+		maps.Copy(t.rawArgCommands, getImageRawArgCommands())
+	}
+	if profile.Format == FormatMarkdown {
+		t.rules.CommandReplacements = maps.Clone(rules.CommandReplacements)
+		maps.Copy(t.rules.CommandReplacements, GetMarkdownCommandReplacements())
+	}
+	return t
+}
+
+var defaultTransformer = sync.OnceValue(func() *Transformer {
+	return NewTransformer(DefaultRules(), Options{})
+})
+
+// Transform reads LaTeX from r and writes the transformed text to w. Since the custom command
+// preamble and references depend on the whole document, w is written once r is exhausted.
+func (t *Transformer) Transform(r io.Reader, w io.Writer) (Report, error) {
+	if t.err != nil {
+		return Report{Diagnostics: []Diagnostic{}}, t.err
+	}
+	info := t.newTransformationInfo()
+	info.rules = GetTransformRules()
+	return info.transform(r, w)
+}
+
+// TransformString transforms latex and collects the output in the result.
+func (t *Transformer) TransformString(latex string) Result {
+	var output strings.Builder
+	report, err := t.Transform(strings.NewReader(latex), &output)
+	if err != nil {
+		return failedResult(err, Report{Diagnostics: report.Diagnostics})
+	}
+	return Result{Transformed: output.String(), Success: true, Report: report}
+}
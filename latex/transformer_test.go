@@ -0,0 +1,36 @@
+package latex
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestTransformerConcurrent is meant to be run with -race.
+func TestTransformerConcurrent(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "golden", "*.tex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transformer := NewTransformer(DefaultRules(), Options{Images: true, Flags: map[string]bool{"solution": true}})
+	var wg sync.WaitGroup
+	for _, input := range inputs {
+		source, err := os.ReadFile(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := transformer.TransformString(string(source))
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				got := transformer.TransformString(string(source))
+				if got.Transformed != want.Transformed || got.LogText() != want.LogText() {
+					t.Errorf("%s: concurrent transformation differs", input)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
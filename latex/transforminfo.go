@@ -1,20 +1,13 @@
 package latex
 
 import (
+	"bytes"
 	"errors"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
-type latexTransFormResult struct {
-	Transformed 	string
-	OperationsLog 	[]string
-	Success 		bool
-	ErrorMessage 	string
-	Log				string
-	Info			string
-}
-
 type mathModeOpen int
 const (
 	notOpen	mathModeOpen = iota
@@ -32,6 +25,7 @@ type braceClosingData struct {
 	escape 		bool
 	depth		int
 	replacement	string
+	onClose		func(info *latexTransformationInfo)
 }
 
 type bracketClosingData struct {
@@ -39,8 +33,32 @@ type bracketClosingData struct {
 	replacement	string
 }
 
+type rawArgCommand struct {
+	optArg		bool
+	html		bool
+	handler		func(info *latexTransformationInfo, opt string, arg string) error
+}
+
+type verbatimState struct {
+	environ		string
+	delimiter	rune
+	star		bool
+	discard		bool
+	table		bool
+	start		Position
+}
+
+type rawArgState struct {
+	command		string
+	opt			string
+	arg			string
+	inOpt		bool
+	optDone		bool
+	depth		int
+}
+
 type latexTransformationInfo struct {
-	current_string			string
+	output					bytes.Buffer
 	openBraces				int
 	mode 					mathModeOpen
 	envMode 				environMode
@@ -49,10 +67,55 @@ type latexTransformationInfo struct {
 	knownMathEnvirons   	map[string]bool
 	braceReplacement 		[]braceClosingData
 	environmentStack 		[]string
-	environmentReplacements map[string]envReplacement
+	environmentReplacements map[string]EnvReplacement
 	bracketReplacement		*bracketClosingData
 	html					bool
+	htmlWritten				bool // tags were written, even if the output is not html
 	logMap						map[string]int
+	options					Options
+	rawArgCommands			map[string]rawArgCommand
+	rawArgs					rawArgState
+	files					[]EmbeddedFile
+	embeddedNames			map[string]string // name of each embedded file by its resolved path
+	theoremNames			map[string]string
+	theoremCounter			int
+	verbatimEnvirons		map[string]VerbatimArg
+	verbatim				verbatimState
+	body					strings.Builder // text of comments, verbatim and theorem titles, which can be long
+	title					groupTracker
+	commentEnvirons			map[string]bool
+	flags					map[string]bool
+	conditionals			[]conditional
+	skip					skipState
+	textAccents				map[string]TextAccent
+	textSymbols				map[string]string
+	accent					accentState
+	germanShorthands		map[rune]string
+	pos						Position
+	tokenPos				Position
+	diagnostics				[]Diagnostic
+	mathjaxCommands			map[string]bool
+	mathjaxEnvirons			map[string]bool
+	rules					map[string]Severity
+	pendingItem				*Position
+	mathOnlyCommands		map[string]bool
+	mathSymbols				map[rune]string
+	mathText				int
+	controlWordEnd			bool
+	hardBreak				bool // a Markdown line break was written, the next character starts a new line
+	labels					map[string]string
+	currentLabel			string
+	outerLabels			[]string
+	references				[]reference
+	equation				equationState
+	equationCounter			int
+	itemCounters			[]int
+	footnotes				[]string
+	err						error
+	profile					Profile
+	document				bool
+	documentEnded			bool
+	includeStack			[]string
 }
 
 func (l *latexTransformationInfo) log(s string) {
@@ -64,19 +127,36 @@ func (l *latexTransformationInfo) log(s string) {
 	}
 }
 
-func (l *latexTransformationInfo) getEnvRepl(env string) (envReplacement, bool) {
+func (l *latexTransformationInfo) diagnose(rule string, pos Position, message string) {
+	severity, ok := l.rules[rule]
+	if !ok {
+		return
+	}
+	l.diagnostics = append(l.diagnostics, Diagnostic{rule, severity, pos, message})
+}
+
+func (l *latexTransformationInfo) advancePosition(char rune) {
+	if char == '\n' {
+		l.pos.Line += 1
+		l.pos.Column = 1
+	} else {
+		l.pos.Column += 1
+	}
+}
+
+func (l *latexTransformationInfo) getEnvRepl(env string) (EnvReplacement, bool) {
 	val, ok := l.environmentReplacements[env]
 	return val, ok
 }
 
-func (l *latexTransformationInfo) getEnvCommandRepl(env string, command string) (commandReplacement, bool) {
+func (l *latexTransformationInfo) getEnvCommandRepl(env string, command string) (CommandReplacement, bool) {
 	repl, ok := l.environmentReplacements[env]
 	if !ok {
-		return commandReplacement{}, false
+		return CommandReplacement{}, false
 	}
-	inner_repl, ok := repl.innerRepl[command]
+	inner_repl, ok := repl.InnerRepl[command]
 	if !ok {
-		return commandReplacement{}, false
+		return CommandReplacement{}, false
 	}
 	return inner_repl, true
 }
@@ -94,8 +174,52 @@ func (l *latexTransformationInfo) setBracketReplacement(b bracketClosingData) {
 	l.bracketReplacement = &b
 }
 
-func (l *latexTransformationInfo) setHtmlIfNeeded(original string) {
-	l.html = strings.Contains(original, "\\begin{enumerate}") || strings.Contains(original, "\\begin{itemize}")
+func (l *latexTransformationInfo) getRawArgCommand(command string) (rawArgCommand, bool) {
+	val, ok := l.rawArgCommands[command]
+	return val, ok
+}
+
+func (l *latexTransformationInfo) startRawArgs(command string) {
+	l.rawArgs = rawArgState{command: command}
+	l.setPrevToken(token{rawArgs, ""})
+}
+
+func (l *latexTransformationInfo) isVerbatimEnviron(env string) bool {
+	_, ok := l.verbatimEnvirons[env]
+	return ok
+}
+
+func (l *latexTransformationInfo) isCommentEnviron(env string) bool {
+	_, ok := l.commentEnvirons[env]
+	return ok
+}
+
+func (l *latexTransformationInfo) conditionalValue(name string) (bool, bool) {
+	switch name {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+	val, ok := l.flags[name]
+	return val, ok
+}
+
+func (l *latexTransformationInfo) isDeclaredFlag(flag string) bool {
+	_, ok := l.flags[flag]
+	return ok
+}
+
+func (l *latexTransformationInfo) isSkipping() bool {
+	if len(l.conditionals) == 0 {
+		return false
+	}
+	return !l.conditionals[len(l.conditionals)-1].taking()
+}
+
+func (l *latexTransformationInfo) nextTheoremNumber() int {
+	l.theoremCounter += 1
+	return l.theoremCounter
 }
 
 func (l *latexTransformationInfo) getEnv() environMode {
@@ -106,20 +230,103 @@ func (l *latexTransformationInfo) setEnv(env environMode) {
 	l.envMode = env
 }
 
+// Text is written with placeholders for the characters whose output depends on html mode, which
+// is only known once the whole input is read. Math keeps & and line breaks, MathJax needs them.
+const (
+	ampersandPlaceholder = "\ue010"
+	lessThanPlaceholder = "\ue011"
+	greaterThanPlaceholder = "\ue012"
+	newlinePlaceholder = "\ue013"
+	nbspPlaceholder = "\ue014"
+	backslashPlaceholder = "\ue015"
+)
+
+var textPlaceholders = strings.NewReplacer("&", ampersandPlaceholder, "<", lessThanPlaceholder, ">", greaterThanPlaceholder, "\n", newlinePlaceholder)
+var mathPlaceholders = strings.NewReplacer("<", lessThanPlaceholder, ">", greaterThanPlaceholder)
+
+// mathTextCommands switch to text in math, their argument is not written as math.
+var mathTextCommands = map[string]bool{
+	"text": true, "textbf": true, "textit": true, "textrm": true, "textsf": true, "texttt": true,
+	"textup": true, "textnormal": true, "mbox": true, "hbox": true, "fbox": true,
+}
+
 func (l *latexTransformationInfo) addToOutputString(text string) {
-	if l.html {
-		escaped := strings.Replace(text, "&", "&amp;", -1)
-		escaped = strings.Replace(escaped, "<", "&lt;", -1)
-		escaped = strings.Replace(escaped, ">", "&gt;", -1)
-		escaped = strings.Replace(escaped, "\n", "<br>\n", -1)
-		l.current_string += escaped
+	if l.isMathContext() {
+		if l.controlWordEnd && text != "" && isASCIILetter(text[0]) {
+			l.output.WriteByte(' ')
+		}
+		l.controlWordEnd = false
+		if l.options.MathRelationCommands && l.mathText == 0 && strings.ContainsAny(text, "<>") {
+			l.writeMathRelations(text)
+		} else if !strings.ContainsAny(text, "<>") {
+			l.output.WriteString(text)
+		} else {
+			mathPlaceholders.WriteString(&l.output, text)
+		}
+		return
+	}
+	l.controlWordEnd = false
+	if l.isMarkdown() {
+		text = l.escapeMarkdownBlockMarker(markdownEscaper.Replace(text))
+	}
+	if strings.ContainsAny(text, "&<>\n") {
+		textPlaceholders.WriteString(&l.output, text)
 	} else {
-		l.current_string += text
+		l.output.WriteString(text)
 	}
 }
 
+// addRawToOutputString writes text as it is. Writing a tag makes the output html.
 func (l *latexTransformationInfo) addRawToOutputString(text string) {
-		l.current_string += text
+	if strings.Contains(text, "<") {
+		l.htmlWritten = true
+		l.useHtml()
+	}
+	l.controlWordEnd = false
+	l.output.WriteString(text)
+}
+
+// writeMathRelations writes < and > as \lt and \gt, separated by a space from a following letter.
+func (l *latexTransformationInfo) writeMathRelations(text string) {
+	for _, char := range text {
+		if l.controlWordEnd && char < utf8.RuneSelf && isASCIILetter(byte(char)) {
+			l.output.WriteByte(' ')
+		}
+		l.controlWordEnd = char == '<' || char == '>'
+		switch char {
+		case '<':
+			l.output.WriteString("\\lt")
+		case '>':
+			l.output.WriteString("\\gt")
+		default:
+			l.output.WriteRune(char)
+		}
+	}
+}
+
+// startMathText treats the argument of a text command in math as text until its brace closes.
+func (l *latexTransformationInfo) startMathText() {
+	l.mathText += 1
+	l.pushClosingBraceAction(braceClosingData{false, l.getOpenBraces(), "}", func(info *latexTransformationInfo) {
+		info.mathText -= 1
+	}})
+}
+
+// useHtml escapes the text of the whole output for html, unless the policy forbids it.
+func (l *latexTransformationInfo) useHtml() {
+	if l.options.HTML != HTMLNever {
+		l.html = true
+	}
+}
+
+// outputReplacer replaces the text placeholders for the final output.
+func (l *latexTransformationInfo) outputReplacer() *strings.Replacer {
+	if l.html {
+		return strings.NewReplacer(ampersandPlaceholder, "&amp;", lessThanPlaceholder, "&lt;", greaterThanPlaceholder, "&gt;",
+			newlinePlaceholder, "<br>\n", nbspPlaceholder, "&nbsp;", backslashPlaceholder, "&#92;")
+	}
+	return strings.NewReplacer(ampersandPlaceholder, "&", lessThanPlaceholder, "<", greaterThanPlaceholder, ">",
+		newlinePlaceholder, "\n", nbspPlaceholder, "\u00a0", backslashPlaceholder, "\\")
 }
 
 func (l *latexTransformationInfo) anyClosingBraceAction() bool {
@@ -173,28 +380,16 @@ func (l *latexTransformationInfo) addCommandUsage(command string) {
 	l.commands.usedCustomCommands[command] = true
 }
 
-func (l *latexTransformationInfo) getCommandReplacement(command string) (commandReplacement, bool) {
+func (l *latexTransformationInfo) getCommandReplacement(command string) (CommandReplacement, bool) {
 	val, ok := l.commands.commandReplacements[command]	
 	if !ok {
 		env, envExits := l.getCurrEnv()
 		if !envExits {
-			return commandReplacement{}, false
+			return CommandReplacement{}, false
 		}
 		// special case enumerate for convenience
-		if env == "enumerate" && command == "item" {
-			enumerateCount := 0
-			for _, environ := range l.environmentStack {
-				if environ == "enumerate" {
-					enumerateCount += 1
-				}
-			}
-			if enumerateCount == 1 {
-				return commandReplacement{false, false, false, "<li type=\"a\">)", ""}, true
-			} else if enumerateCount == 2 {
-				return commandReplacement{false, false, false, "<li type=\"i\">)", ""}, true
-			} else {
-				return commandReplacement{false, false, false, "<li type=\"A\">)", ""}, true
-			}
+		if env == "enumerate" && command == "item" && l.profile.Lists == ListLetters {
+			return CommandReplacement{false, false, false, "<li" + formatAttribute("type", enumerateType(l.enumerateDepth())) + ">)", ""}, true
 		}
 		repl, found := l.getEnvCommandRepl(env, command)
 		return repl, found
@@ -204,6 +399,8 @@ func (l *latexTransformationInfo) getCommandReplacement(command string) (command
 
 func (l *latexTransformationInfo) addEnvironment(env string) {
 	l.environmentStack = append(l.environmentStack, env)
+	// like a TeX group, an environment restores the current label when it ends
+	l.outerLabels = append(l.outerLabels, l.currentLabel)
 }
 
 func (l *latexTransformationInfo) getCurrEnv() (string, bool) {
@@ -222,6 +419,10 @@ func (l *latexTransformationInfo) popEnvironment(env string) error {
 		return errors.New("unexpected environment closure: " + string(env) +", last open environment: " + lastEnv)
 	}
 	l.environmentStack = l.environmentStack[0:len(l.environmentStack) - 1]
+	if len(l.outerLabels) > 0 {
+		l.currentLabel = l.outerLabels[len(l.outerLabels) - 1]
+		l.outerLabels = l.outerLabels[0:len(l.outerLabels) - 1]
+	}
 	return nil
 }
 
@@ -237,27 +438,76 @@ func (l *latexTransformationInfo) isMathModeActive() bool {
 	return l.mode != notOpen
 }
 
+func (l *latexTransformationInfo) enumerateDepth() int {
+	depth := 0
+	for _, environ := range l.environmentStack {
+		if environ == "enumerate" {
+			depth += 1
+		}
+	}
+	return depth
+}
+
+func enumerateType(depth int) string {
+	switch depth {
+	case 1:
+		return "a"
+	case 2:
+		return "i"
+	default:
+		return "A"
+	}
+}
+
+func (l *latexTransformationInfo) inlineDelimiters() (string, string) {
+	return l.options.InlineMath.pair(DelimiterParens)
+}
+
+func (l *latexTransformationInfo) displayDelimiters() (string, string) {
+	return l.options.DisplayMath.pair(DelimiterBrackets)
+}
+
+func (l *latexTransformationInfo) environDelimiters() (string, string) {
+	if l.options.EnvironWrap == DelimiterNone {
+		return "", ""
+	}
+	return l.options.EnvironWrap.pair(DelimiterParens)
+}
+
 func (l *latexTransformationInfo) preamble() string {
 	return CreateCustomCommandPreamble(&l.commands.usedCustomCommands, l)
 }
 
+// invariantError records a violated internal invariant; the transformation stops with it after the current character.
+func (l *latexTransformationInfo) invariantError(message string) {
+	if l.err == nil {
+		l.err = errors.New("internal error: " + message)
+	}
+}
+
 func (l *latexTransformationInfo) setPrevToken(tk token) {
-	if tk.ttype != backslashOngoing && tk.ttype != environClose && tk.ttype != environOpen && tk.tokenInfo != "" {
-		panic("Token info should be empty! Token of type " + tk.ttype.toString() + " does not contain info!")
+	if !tk.ttype.carriesInfo() && tk.tokenInfo != "" {
+		l.invariantError("token info should be empty, token of type " + tk.ttype.toString() + " does not contain info")
+		tk.tokenInfo = ""
+	}
+	if tk.ttype == backslash || tk.ttype == dollar {
+		l.tokenPos = l.pos
 	}
 	l.prevToken = tk
 }
 
 func (l *latexTransformationInfo) getTokenInfo() string {
-	if l.prevToken.ttype != backslashOngoing && l.prevToken.ttype != environClose && l.prevToken.ttype != environOpen {
-		panic("Token info shoud not be requested for token of type " + l.prevToken.ttype.toString() + "!")
+	if !l.prevToken.ttype.carriesInfo() {
+		l.invariantError("token info should not be requested for token of type " + l.prevToken.ttype.toString())
+		return ""
 	}
 	return l.prevToken.tokenInfo
 }
 
 func (l *latexTransformationInfo) addTokenInfo(s string) {
-	if l.prevToken.ttype != backslashOngoing && l.prevToken.ttype != environClose && l.prevToken.ttype != environOpen {
-		panic("Token info shoud not be added for token of type " + l.prevToken.ttype.toString() + "!")
+	if !l.prevToken.ttype.carriesInfo() {
+		l.invariantError("token info should not be added for token of type " + l.prevToken.ttype.toString())
+		return
 	}
 	l.prevToken.tokenInfo += s
 }
@@ -267,8 +517,10 @@ func (l *latexTransformationInfo) getTokenType() tokenType {
 }
 
 type commandHandling struct {
-	commandReplacements map[string]commandReplacement
+	commandReplacements map[string]CommandReplacement
 	customCommands 		map[string]string
+	customCommandOrder	[]string
+	customCommandDependencies map[string][]string
 	usedCustomCommands 	map[string]bool
 }
 
@@ -281,6 +533,16 @@ const (
 	dollar
 	comment
 	none
+	rawArgs
+	envOptArg
+	verbatimBody
+	verbInline
+	newifDecl
+	accentArg
+	textSymbolEnd
+	ligature
+	babelShorthand
+	theoremTitle
 )
 
 func (t tokenType) toString() string {
@@ -299,11 +561,35 @@ func (t tokenType) toString() string {
 		return "comment"
 	case none:
 		return "none"
+	case rawArgs:
+		return "rawArgs"
+	case envOptArg:
+		return "envOptArg"
+	case verbatimBody:
+		return "verbatimBody"
+	case verbInline:
+		return "verbInline"
+	case newifDecl:
+		return "newifDecl"
+	case accentArg:
+		return "accentArg"
+	case textSymbolEnd:
+		return "textSymbolEnd"
+	case ligature:
+		return "ligature"
+	case babelShorthand:
+		return "babelShorthand"
+	case theoremTitle:
+		return "theoremTitle"
 	default:
-		panic("unknwon token of index: " + strconv.Itoa(int(t)))
+		return "unknown(" + strconv.Itoa(int(t)) + ")"
 	}
 }
 
+func (t tokenType) carriesInfo() bool {
+	return t == backslashOngoing || t == environClose || t == environOpen || t == envOptArg || t == newifDecl || t == textSymbolEnd || t == ligature
+}
+
 type token struct {
 	ttype			tokenType
 	tokenInfo		string
@@ -0,0 +1,109 @@
+package latex
+
+import (
+	"slices"
+	"unicode/utf8"
+)
+
+const (
+	htmlUnbalancedRule = "html-unbalanced"
+	htmlNotAllowedRule = "html-not-allowed"
+)
+
+// hasOptionalEndTag reports whether the end tag of an element may be left out.
+func hasOptionalEndTag(name string) bool {
+	switch name {
+	case "li", "p", "td", "th", "tr", "thead", "tbody", "dt", "dd", "option":
+		return true
+	}
+	return false
+}
+
+type htmlProblem struct {
+	rule    string
+	offset  int
+	message string
+}
+
+// validateHTML checks that the tags of s are balanced and, unless allowed is nil, that every tag
+// and attribute is in the allow-list.
+func validateHTML(s string, allowed map[string][]string) []htmlProblem {
+	problems := make([]htmlProblem, 0)
+	open := make([]htmlToken, 0)
+	for _, tag := range tokenizeHTML(s) {
+		if tag.ttype == htmlText {
+			continue
+		}
+		if allowed != nil {
+			attributes, ok := allowed[tag.name]
+			if !ok {
+				if tag.ttype == htmlStartTag {
+					problems = append(problems, htmlProblem{htmlNotAllowedRule, tag.offset, "tag <" + tag.name + "> is not allowed"})
+				}
+			} else {
+				for _, attr := range tag.attributes {
+					if !slices.Contains(attributes, attr.name) {
+						problems = append(problems, htmlProblem{htmlNotAllowedRule, tag.offset, "attribute " + attr.name + " of <" + tag.name + "> is not allowed"})
+					}
+				}
+			}
+		}
+		if tag.ttype == htmlStartTag {
+			if tag.selfClosing || isVoidElement(tag.name) {
+				continue
+			}
+			if len(open) > 0 && open[len(open)-1].name == tag.name && hasOptionalEndTag(tag.name) {
+				open = open[:len(open)-1]
+			}
+			open = append(open, tag)
+			continue
+		}
+		if isVoidElement(tag.name) {
+			continue
+		}
+		match := len(open) - 1
+		for match >= 0 && open[match].name != tag.name {
+			match -= 1
+		}
+		if match < 0 {
+			problems = append(problems, htmlProblem{htmlUnbalancedRule, tag.offset, "end tag </" + tag.name + "> without start tag"})
+			continue
+		}
+		for _, unclosed := range open[match+1:] {
+			if !hasOptionalEndTag(unclosed.name) {
+				problems = append(problems, htmlProblem{htmlUnbalancedRule, unclosed.offset, "tag <" + unclosed.name + "> is not closed before </" + tag.name + ">"})
+			}
+		}
+		open = open[:match]
+	}
+	for _, unclosed := range open {
+		if !hasOptionalEndTag(unclosed.name) {
+			problems = append(problems, htmlProblem{htmlUnbalancedRule, unclosed.offset, "tag <" + unclosed.name + "> is not closed"})
+		}
+	}
+	return problems
+}
+
+// validateOutput reports the problems of the html output as diagnostics. They have no input
+// position, the message names the line and column in the output instead.
+func (l *latexTransformationInfo) validateOutput(output string) {
+	pos := Position{Line: 1, Column: 1}
+	done := 0
+	for _, problem := range validateHTML(output, l.profile.AllowedTags) {
+		// problems are ordered by offset apart from unclosed tags, which restart the count
+		if problem.offset < done {
+			pos, done = Position{Line: 1, Column: 1}, 0
+		}
+		for done < problem.offset {
+			char, size := utf8.DecodeRuneInString(output[done:])
+			if char == '\n' {
+				pos.Line += 1
+				pos.Column = 1
+			} else {
+				pos.Column += 1
+			}
+			done += size
+		}
+		l.diagnose(problem.rule, Position{}, problem.message+" at output "+pos.String())
+	}
+}
@@ -0,0 +1,70 @@
+package latex
+
+import (
+	"maps"
+	"reflect"
+	"testing"
+)
+
+func TestValidateHTML(t *testing.T) {
+	allowed := map[string][]string{"ol": {"type"}, "li": {}, "strong": {}, "br": {}, "div": {"class"}}
+	tests := []struct {
+		html string
+		want []htmlProblem
+	}{
+		{"<ol type=\"a\"><li>one<li>two</ol><br>", []htmlProblem{}},
+		{"<div class=\"proof\"><strong>x</div>", []htmlProblem{
+			{htmlUnbalancedRule, 19, "tag <strong> is not closed before </div>"},
+		}},
+		{"text</strong>", []htmlProblem{
+			{htmlUnbalancedRule, 4, "end tag </strong> without start tag"},
+		}},
+		{"<div>open", []htmlProblem{
+			{htmlUnbalancedRule, 0, "tag <div> is not closed"},
+		}},
+		{"<span>x</span><div style=\"a\"></div>", []htmlProblem{
+			{htmlNotAllowedRule, 0, "tag <span> is not allowed"},
+			{htmlNotAllowedRule, 14, "attribute style of <div> is not allowed"},
+		}},
+		{"<!-- <span> --><br/>", []htmlProblem{}},
+	}
+	for _, test := range tests {
+		got := validateHTML(test.html, allowed)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("validateHTML(%q) = %v, want %v", test.html, got, test.want)
+		}
+	}
+}
+
+func TestTransformReportsDisallowedHTML(t *testing.T) {
+	registerTestProfile(t, Profile{Name: "test-no-lists", AllowedTags: map[string][]string{"br": {}}})
+	result := TransformLatexWithOptions("\\begin{itemize}\n\\item a\n\\end{itemize}", Options{Profile: "test-no-lists"})
+	if !result.Success {
+		t.Fatal(result.ErrorMessage)
+	}
+	want := []Diagnostic{
+		{htmlNotAllowedRule, SeverityWarning, Position{}, "tag <ul> is not allowed at output 1:1"},
+		{htmlNotAllowedRule, SeverityWarning, Position{}, "tag <li> is not allowed at output 2:1"},
+	}
+	if !reflect.DeepEqual(result.Diagnostics, want) {
+		t.Errorf("diagnostics = %v, want %v", result.Diagnostics, want)
+	}
+}
+
+// registerTestProfile registers profile for the duration of the test.
+func registerTestProfile(t *testing.T, profile Profile) {
+	restoreProfiles(t)
+	RegisterProfiles(profile)
+}
+
+// restoreProfiles restores the registered profiles after the test.
+func restoreProfiles(t *testing.T) {
+	profileRegistry.RLock()
+	saved := maps.Clone(profileRegistry.profiles)
+	profileRegistry.RUnlock()
+	t.Cleanup(func() {
+		profileRegistry.Lock()
+		defer profileRegistry.Unlock()
+		profileRegistry.profiles = saved
+	})
+}
@@ -0,0 +1,129 @@
+package latex
+
+import (
+	"html"
+	"strings"
+)
+
+func handlePrevVerbatimBody(char rune, info *latexTransformationInfo) error {
+	info.body.WriteRune(char)
+	body := info.body.String()
+	end := "\\end{" + info.verbatim.environ + "}"
+	if !strings.HasSuffix(body, end) {
+		return nil
+	}
+	info.setPrevToken(token{none, ""})
+	if info.verbatim.discard {
+		info.log("Removed environment " + info.verbatim.environ)
+		return nil
+	}
+	body = strings.TrimSuffix(body, end)
+	if info.verbatim.table {
+		info.log("Replaced environment " + info.verbatim.environ + " with Markdown table")
+		table, err := info.markdownTable(body)
+		if err != nil {
+			return err
+		}
+		info.addRawToOutputString(table)
+		return nil
+	}
+	language := ""
+	switch info.verbatimEnvirons[info.verbatim.environ] {
+	case OptionsVerbatimArg:
+		if options, rest, ok := cutDelimited(body, '[', ']'); ok {
+			body = rest
+			for _, option := range strings.Split(options, ",") {
+				key, value, _ := strings.Cut(option, "=")
+				if strings.TrimSpace(key) == "language" {
+					language = strings.TrimSpace(value)
+				}
+			}
+		}
+	case LanguageVerbatimArg:
+		if _, rest, ok := cutDelimited(body, '[', ']'); ok {
+			body = rest
+		}
+		if lang, rest, ok := cutDelimited(body, '{', '}'); ok {
+			body = rest
+			language = strings.TrimSpace(lang)
+		}
+	}
+	body = strings.TrimPrefix(body, "\n")
+	body = strings.TrimSuffix(body, "\n")
+	codeTag := "<code>"
+	if language != "" {
+		codeTag = "<code" + formatAttribute("class", "language-"+strings.ToLower(language)) + ">"
+	}
+	info.log("Replaced environment " + info.verbatim.environ + " with <pre><code>...</code></pre>")
+	info.addRawToOutputString("<pre>" + codeTag + html.EscapeString(body) + "</code></pre>")
+	return nil
+}
+
+func handlePrevVerbInline(char rune, info *latexTransformationInfo) {
+	state := &info.verbatim
+	if state.delimiter == 0 {
+		if char == '*' && !state.star {
+			state.star = true
+		} else {
+			state.delimiter = char
+		}
+		return
+	}
+	if char != state.delimiter {
+		info.body.WriteRune(char)
+		return
+	}
+	code := info.body.String()
+	if state.star {
+		code = strings.ReplaceAll(code, " ", "␣")
+	}
+	info.setPrevToken(token{none, ""})
+	info.log("Replaced \\verb with <code>...</code>")
+	info.addRawToOutputString("<code>" + html.EscapeString(code) + "</code>")
+}
+
+// cutDelimited splits a leading open...close group off s, returning its content and the rest.
+func cutDelimited(s string, open rune, close rune) (string, string, bool) {
+	if !strings.HasPrefix(s, string(open)) {
+		return "", s, false
+	}
+	content, rest, found := strings.Cut(s[1:], string(close))
+	if !found {
+		return "", s, false
+	}
+	return content, rest, true
+}
+
+// groupTracker follows braces and inline math to find the characters at the top level of an
+// argument.
+type groupTracker struct {
+	depth   int
+	math    bool
+	escaped bool
+}
+
+// topLevel reports whether char is neither escaped nor in a group or math, and moves past it.
+func (g *groupTracker) topLevel(char rune) bool {
+	if g.escaped {
+		g.escaped = false
+		if char == '(' {
+			g.math = true
+		} else if char == ')' {
+			g.math = false
+		}
+		return false
+	}
+	switch char {
+	case '\\':
+		g.escaped = true
+	case '{':
+		g.depth += 1
+	case '}':
+		g.depth -= 1
+	case '$':
+		g.math = !g.math
+	default:
+		return g.depth == 0 && !g.math
+	}
+	return false
+}
@@ -1,11 +1,16 @@
 package main
 
 import (
+    "os"
     // "stacklatex/frontendweb"
+    "stacklatex/frontendcli"
     "stacklatex/frontenddesktop"
 )
 
 func main() {
+    if len(os.Args) > 1 {
+        os.Exit(frontendcli.Run(os.Args[1:]))
+    }
     // frontendweb.ServeWeb("1500")
     frontenddesktop.RunDesktopApp()
-}
\ No newline at end of file
+}